@@ -1,9 +1,12 @@
 package azurerm
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/arm/cdn"
 	"github.com/Azure/azure-sdk-for-go/arm/compute"
@@ -60,6 +63,11 @@ type ArmClient struct {
 	storageUsageClient   storage.UsageOperationsClient
 
 	deploymentsClient resources.DeploymentsClient
+
+	// operationTimeout and pollingInterval are the provider defaults for
+	// waiting on long-running operations. Zero means the built-in default.
+	operationTimeout time.Duration
+	pollingInterval  time.Duration
 }
 
 func withRequestLogging() autorest.SendDecorator {
@@ -77,6 +85,63 @@ func withRequestLogging() autorest.SendDecorator {
 	}
 }
 
+const (
+	// throttlingRetryAttempts is the number of times a request which was
+	// throttled by Azure Resource Manager is retried before giving up.
+	throttlingRetryAttempts = 5
+
+	// throttlingRetryBackoff is the base delay used for exponential backoff
+	// when a throttled response carries no Retry-After header.
+	throttlingRetryBackoff = 5 * time.Second
+
+	// throttlingRetryMaxDelay caps the delay between two retries.
+	throttlingRetryMaxDelay = 2 * time.Minute
+)
+
+// withThrottlingRetry returns a SendDecorator which retries requests that Azure
+// Resource Manager rejected with HTTP 429 (Too Many Requests). The delay between
+// attempts honours the Retry-After header when present, and otherwise backs off
+// exponentially from the given base delay, never exceeding maxDelay.
+func withThrottlingRetry(attempts int, backoff time.Duration, maxDelay time.Duration) autorest.SendDecorator {
+	return func(s autorest.Sender) autorest.Sender {
+		return autorest.SenderFunc(func(r *http.Request) (*http.Response, error) {
+			var body []byte
+			if r.Body != nil {
+				var err error
+				body, err = ioutil.ReadAll(r.Body)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			for attempt := 0; ; attempt++ {
+				if r.Body != nil {
+					r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+				}
+
+				resp, err := s.Do(r)
+				if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt >= attempts {
+					return resp, err
+				}
+
+				delay := autorest.GetRetryAfter(resp, backoff*time.Duration(1<<uint(attempt)))
+				if delay > maxDelay {
+					delay = maxDelay
+				}
+
+				autorest.Respond(resp, autorest.ByClosing())
+				log.Printf("[WARN] Azure RM Request %q to %q was throttled, retrying in %s (attempt %d of %d)", r.Method, r.URL, delay, attempt+1, attempts)
+
+				select {
+				case <-time.After(delay):
+				case <-r.Cancel:
+					return resp, fmt.Errorf("Request to %s was cancelled while waiting to retry after being throttled", r.URL)
+				}
+			}
+		})
+	}
+}
+
 func setUserAgent(client *autorest.Client) {
 	var version string
 	if terraform.VersionPrerelease != "" {
@@ -92,7 +157,10 @@ func setUserAgent(client *autorest.Client) {
 // *ArmClient based on the Config's current settings.
 func (c *Config) getArmClient() (*ArmClient, error) {
 	// client declarations:
-	client := ArmClient{}
+	client := ArmClient{
+		operationTimeout: c.OperationTimeout,
+		pollingInterval:  c.PollingInterval,
+	}
 
 	rivieraClient, err := riviera.NewClient(&riviera.AzureResourceManagerCredentials{
 		ClientID:       c.ClientID,
@@ -170,7 +238,7 @@ func (c *Config) getArmClient() (*ArmClient, error) {
 	vmssc := compute.NewVirtualMachineScaleSetsClient(c.SubscriptionID)
 	setUserAgent(&vmssc.Client)
 	vmssc.Authorizer = spt
-	vmssc.Sender = autorest.CreateSender(withRequestLogging())
+	vmssc.Sender = autorest.CreateSender(withRequestLogging(), withThrottlingRetry(throttlingRetryAttempts, throttlingRetryBackoff, throttlingRetryMaxDelay))
 	client.vmScaleSetClient = vmssc
 
 	vmc := compute.NewVirtualMachinesClient(c.SubscriptionID)
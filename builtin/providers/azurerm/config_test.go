@@ -0,0 +1,73 @@
+package azurerm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+)
+
+func TestWithThrottlingRetry(t *testing.T) {
+	testCases := []struct {
+		throttledResponses int
+		expectedStatusCode int
+		expectedCalls      int
+	}{
+		{0, http.StatusOK, 1},
+		{2, http.StatusOK, 3},
+		{3, http.StatusTooManyRequests, 3},
+	}
+
+	for _, test := range testCases {
+		calls := 0
+		var bodies []string
+		sender := autorest.SenderFunc(func(r *http.Request) (*http.Response, error) {
+			calls++
+
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Fatalf("Error reading request body: %s", err)
+			}
+			bodies = append(bodies, string(b))
+
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+			}
+			if calls <= test.throttledResponses {
+				resp.StatusCode = http.StatusTooManyRequests
+				resp.Header.Set("Retry-After", "0")
+			}
+
+			return resp, nil
+		})
+
+		req, err := http.NewRequest("PUT", "https://management.azure.com/", bytes.NewBufferString(`{"sku":{}}`))
+		if err != nil {
+			t.Fatalf("Error creating request: %s", err)
+		}
+
+		resp, err := autorest.DecorateSender(sender, withThrottlingRetry(2, time.Millisecond, time.Second)).Do(req)
+		if err != nil {
+			t.Fatalf("Unexpected error sending request: %s", err)
+		}
+
+		if resp.StatusCode != test.expectedStatusCode {
+			t.Fatalf("Expected status code %d after %d throttled responses, got %d", test.expectedStatusCode, test.throttledResponses, resp.StatusCode)
+		}
+
+		if calls != test.expectedCalls {
+			t.Fatalf("Expected %d calls after %d throttled responses, got %d", test.expectedCalls, test.throttledResponses, calls)
+		}
+
+		for _, body := range bodies {
+			if body != `{"sku":{}}` {
+				t.Fatalf("Expected the request body to be resent on every attempt, got %q", body)
+			}
+		}
+	}
+}
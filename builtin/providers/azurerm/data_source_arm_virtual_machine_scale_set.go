@@ -0,0 +1,521 @@
+package azurerm
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func dataSourceArmVirtualMachineScaleSet() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceArmVirtualMachineScaleSetRead,
+
+		Schema: map[string]*schema.Schema{
+			"name": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"resource_group_name": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"location": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"sku": &schema.Schema{
+				Type:     schema.TypeSet,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"tier": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"capacity": &schema.Schema{
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+					},
+				},
+				Set: resourceArmVirtualMachineScaleSetSkuHash,
+			},
+
+			"upgrade_policy_mode": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"overprovision": &schema.Schema{
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+
+			"provisioning_state": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"instances": &schema.Schema{
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+
+			"instance_provisioning_states": &schema.Schema{
+				Type:     schema.TypeMap,
+				Computed: true,
+			},
+
+			"os_profile": &schema.Schema{
+				Type:     schema.TypeSet,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"computer_name_prefix": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"admin_username": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+				Set: resourceArmVirtualMachineScaleSetsOsProfileHash,
+			},
+
+			"os_profile_secrets": &schema.Schema{
+				Type:     schema.TypeSet,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"source_vault_id": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"vault_certificates": &schema.Schema{
+							Type:     schema.TypeSet,
+							Computed: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"certificate_url": &schema.Schema{
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+									"certificate_store": &schema.Schema{
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+								},
+							},
+							Set: resourceArmVirtualMachineScaleSetVaultCertificatesHash,
+						},
+					},
+				},
+			},
+
+			"os_profile_windows_config": &schema.Schema{
+				Type:     schema.TypeSet,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"provision_vm_agent": &schema.Schema{
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"enable_automatic_upgrades": &schema.Schema{
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"winrm": &schema.Schema{
+							Type:     schema.TypeSet,
+							Computed: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"protocol": &schema.Schema{
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+									"certificate_url": &schema.Schema{
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+								},
+							},
+						},
+						"additional_unattend_config": &schema.Schema{
+							Type:     schema.TypeSet,
+							Computed: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"pass": &schema.Schema{
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+									"component": &schema.Schema{
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+									"setting_name": &schema.Schema{
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+									"content": &schema.Schema{
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+								},
+							},
+						},
+					},
+				},
+				Set: resourceArmVirtualMachineScaleSetOsProfileLWindowsConfigHash,
+			},
+
+			"os_profile_linux_config": &schema.Schema{
+				Type:     schema.TypeSet,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"disable_password_authentication": &schema.Schema{
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"ssh_keys": &schema.Schema{
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"path": &schema.Schema{
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+									"key_data": &schema.Schema{
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+								},
+							},
+						},
+					},
+				},
+				Set: resourceArmVirtualMachineScaleSetOsProfileLinuxConfigHash,
+			},
+
+			"network_profile": &schema.Schema{
+				Type:     schema.TypeSet,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"id": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"primary": &schema.Schema{
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+
+						"ip_configuration": &schema.Schema{
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"name": &schema.Schema{
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+
+									"subnet_id": &schema.Schema{
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+
+									"load_balancer_backend_address_pool_ids": &schema.Schema{
+										Type:     schema.TypeSet,
+										Computed: true,
+										Elem:     &schema.Schema{Type: schema.TypeString},
+										Set:      schema.HashString,
+									},
+
+									"application_gateway_backend_address_pool_ids": &schema.Schema{
+										Type:     schema.TypeSet,
+										Computed: true,
+										Elem:     &schema.Schema{Type: schema.TypeString},
+										Set:      schema.HashString,
+									},
+
+									"load_balancer_inbound_nat_pool_ids": &schema.Schema{
+										Type:     schema.TypeSet,
+										Computed: true,
+										Elem:     &schema.Schema{Type: schema.TypeString},
+										Set:      schema.HashString,
+									},
+								},
+							},
+						},
+					},
+				},
+				Set: resourceArmVirtualMachineScaleSetNetworkConfigurationHash,
+			},
+
+			"storage_profile_image_reference": &schema.Schema{
+				Type:     schema.TypeSet,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"publisher": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"offer": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"sku": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"version": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+				Set: resourceArmVirtualMachineScaleSetStorageProfileImageReferenceHash,
+			},
+
+			"storage_profile_os_disk": &schema.Schema{
+				Type:     schema.TypeSet,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"image": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"vhd_containers": &schema.Schema{
+							Type:     schema.TypeSet,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+							Set:      schema.HashString,
+						},
+
+						"caching": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"os_type": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"create_option": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+				Set: resourceArmVirtualMachineScaleSetStorageProfileOsDiskHash,
+			},
+
+			"extension": &schema.Schema{
+				Type:     schema.TypeSet,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"publisher": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"type": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"type_handler_version": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"auto_upgrade_minor_version": &schema.Schema{
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+
+						"settings": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+				Set: resourceArmVirtualMachineScaleSetExtensionHash,
+			},
+
+			"application_health_extension": &schema.Schema{
+				Type:     schema.TypeSet,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"protocol": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
+						"port": &schema.Schema{
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+
+						"request_path": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+				Set: resourceArmVirtualMachineScaleSetApplicationHealthExtensionHash,
+			},
+
+			"tags": &schema.Schema{
+				Type:     schema.TypeMap,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func dataSourceArmVirtualMachineScaleSetRead(d *schema.ResourceData, meta interface{}) error {
+	vmScaleSetClient := meta.(*ArmClient).vmScaleSetClient
+
+	resGroup := d.Get("resource_group_name").(string)
+	name := d.Get("name").(string)
+
+	resp, err := vmScaleSetClient.Get(resGroup, name)
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("Virtual Machine Scale Set %q (Resource Group %q) was not found", name, resGroup)
+	}
+	if err != nil {
+		return fmt.Errorf("Error making Read request on Azure Virtual Machine Scale Set %s: %s", name, err)
+	}
+
+	d.SetId(*resp.ID)
+	d.Set("location", resp.Location)
+
+	if err := d.Set("sku", flattenAzureRmVirtualMachineScaleSetSku(resp.Sku)); err != nil {
+		return fmt.Errorf("[DEBUG] Error setting Virtual Machine Scale Set Sku error: %#v", err)
+	}
+
+	d.Set("upgrade_policy_mode", resp.Properties.UpgradePolicy.Mode)
+	if resp.Properties.OverProvision != nil {
+		d.Set("overprovision", *resp.Properties.OverProvision)
+	}
+	if resp.Properties.ProvisioningState != nil {
+		d.Set("provisioning_state", *resp.Properties.ProvisioningState)
+	}
+
+	// The admin password and custom data are secrets, so are not exposed
+	osProfile := flattenAzureRMVirtualMachineScaleSetOsProfile(d, resp.Properties.VirtualMachineProfile.OsProfile)
+	for _, profile := range osProfile {
+		delete(profile.(map[string]interface{}), "admin_password")
+		delete(profile.(map[string]interface{}), "custom_data")
+	}
+	if err := d.Set("os_profile", osProfile); err != nil {
+		return fmt.Errorf("[DEBUG] Error setting Virtual Machine Scale Set OS Profile error: %#v", err)
+	}
+
+	if resp.Properties.VirtualMachineProfile.OsProfile.Secrets != nil {
+		if err := d.Set("os_profile_secrets", flattenAzureRmVirtualMachineScaleSetOsProfileSecrets(resp.Properties.VirtualMachineProfile.OsProfile.Secrets)); err != nil {
+			return fmt.Errorf("[DEBUG] Error setting Virtual Machine Scale Set OS Profile Secrets error: %#v", err)
+		}
+	}
+
+	if resp.Properties.VirtualMachineProfile.OsProfile.WindowsConfiguration != nil {
+		if err := d.Set("os_profile_windows_config", flattenAzureRmVirtualMachineScaleSetOsProfileWindowsConfig(resp.Properties.VirtualMachineProfile.OsProfile.WindowsConfiguration)); err != nil {
+			return fmt.Errorf("[DEBUG] Error setting Virtual Machine Scale Set OS Profile Windows config error: %#v", err)
+		}
+	}
+
+	if resp.Properties.VirtualMachineProfile.OsProfile.LinuxConfiguration != nil {
+		if err := d.Set("os_profile_linux_config", flattenAzureRmVirtualMachineScaleSetOsProfileLinuxConfig(resp.Properties.VirtualMachineProfile.OsProfile.LinuxConfiguration)); err != nil {
+			return fmt.Errorf("[DEBUG] Error setting Virtual Machine Scale Set OS Profile Linux config error: %#v", err)
+		}
+	}
+
+	if err := d.Set("network_profile", flattenAzureRmVirtualMachineScaleSetNetworkProfile(resp.Properties.VirtualMachineProfile.NetworkProfile)); err != nil {
+		return fmt.Errorf("[DEBUG] Error setting Virtual Machine Scale Set Network Profile error: %#v", err)
+	}
+
+	if resp.Properties.VirtualMachineProfile.StorageProfile.ImageReference != nil {
+		if err := d.Set("storage_profile_image_reference", flattenAzureRmVirtualMachineScaleSetStorageProfileImageReference(resp.Properties.VirtualMachineProfile.StorageProfile.ImageReference)); err != nil {
+			return fmt.Errorf("[DEBUG] Error setting Virtual Machine Scale Set Storage Profile Image Reference error: %#v", err)
+		}
+	}
+
+	if err := d.Set("storage_profile_os_disk", flattenAzureRmVirtualMachineScaleSetStorageProfileOSDisk(resp.Properties.VirtualMachineProfile.StorageProfile.OsDisk)); err != nil {
+		return fmt.Errorf("[DEBUG] Error setting Virtual Machine Scale Set Storage Profile OS Disk error: %#v", err)
+	}
+
+	if resp.Properties.VirtualMachineProfile.ExtensionProfile != nil {
+		extension, err := flattenAzureRmVirtualMachineScaleSetExtensionProfile(d, resp.Properties.VirtualMachineProfile.ExtensionProfile)
+		if err != nil {
+			return fmt.Errorf("[DEBUG] Error flattening Virtual Machine Scale Set Extension Profile error: %#v", err)
+		}
+		if err := d.Set("extension", extension); err != nil {
+			return fmt.Errorf("[DEBUG] Error setting Virtual Machine Scale Set Extension Profile error: %#v", err)
+		}
+
+		if err := d.Set("application_health_extension", flattenAzureRmVirtualMachineScaleSetApplicationHealthExtension(resp.Properties.VirtualMachineProfile.ExtensionProfile)); err != nil {
+			return fmt.Errorf("[DEBUG] Error setting Virtual Machine Scale Set Application Health Extension error: %#v", err)
+		}
+	}
+
+	instanceView, err := vmScaleSetClient.GetInstanceView(resGroup, name)
+	if err != nil {
+		log.Printf("[WARN] Error making Read request on the instance view of Azure Virtual Machine Scale Set %s, leaving instances and instance_provisioning_states unset: %s", name, err)
+	} else {
+		instances, provisioningStates := flattenAzureRmVirtualMachineScaleSetInstanceView(instanceView)
+		d.Set("instances", instances)
+		if err := d.Set("instance_provisioning_states", provisioningStates); err != nil {
+			return fmt.Errorf("[DEBUG] Error setting Virtual Machine Scale Set Instance Provisioning States error: %#v", err)
+		}
+	}
+
+	flattenAndSetTags(d, resp.Tags)
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package azurerm
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/arm/compute"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/hashicorp/terraform/helper/acctest"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccDataSourceAzureRMVirtualMachineScaleSet_basic(t *testing.T) {
+	ri := acctest.RandInt()
+	config := fmt.Sprintf(testAccAzureRMVirtualMachineScaleSet_basicLinux, ri, ri, ri, ri, ri, ri, ri, ri) + testAccDataSourceAzureRMVirtualMachineScaleSet_basic
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testCheckAzureRMVirtualMachineScaleSetDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					testCheckAzureRMVirtualMachineScaleSetExists("azurerm_virtual_machine_scale_set.test"),
+					testCheckAzureRMVirtualMachineScaleSetDataSourceMatches("data.azurerm_virtual_machine_scale_set.test", "azurerm_virtual_machine_scale_set.test"),
+					resource.TestCheckResourceAttr("data.azurerm_virtual_machine_scale_set.test", "provisioning_state", "Succeeded"),
+				),
+			},
+		},
+	})
+}
+
+func TestDataSourceArmVirtualMachineScaleSetRead(t *testing.T) {
+	scaleSet := `{
+		"id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Compute/virtualMachineScaleSets/acctvmss",
+		"name": "acctvmss",
+		"location": "westus",
+		"sku": {"name": "Standard_A0", "tier": "Standard", "capacity": 2},
+		"properties": {
+			"provisioningState": "Succeeded",
+			"upgradePolicy": {"mode": "Manual"},
+			"virtualMachineProfile": {
+				"osProfile": {
+					"computerNamePrefix": "testvm",
+					"adminUsername": "myadmin",
+					"customData": "IyEvYmluL2Jhc2g=",
+					"linuxConfiguration": {
+						"disablePasswordAuthentication": true,
+						"ssh": {"publicKeys": [{"path": "/home/myadmin/.ssh/authorized_keys", "keyData": "ssh-rsa AAAA"}]}
+					},
+					"secrets": [
+						{
+							"sourceVault": {"id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.KeyVault/vaults/acctkv"},
+							"vaultCertificates": [{"certificateUrl": "https://acctkv.vault.azure.net/secrets/cert/1"}]
+						}
+					]
+				},
+				"storageProfile": {
+					"osDisk": {
+						"name": "osDiskProfile",
+						"caching": "ReadWrite",
+						"createOption": "FromImage",
+						"vhdContainers": ["https://acctestsa.blob.core.windows.net/vhds"]
+					}
+				},
+				"networkProfile": {"networkInterfaceConfigurations": []},
+				"extensionProfile": {
+					"extensions": [
+						{
+							"name": "CustomScript",
+							"properties": {
+								"publisher": "Microsoft.Azure.Extensions",
+								"type": "CustomScript",
+								"typeHandlerVersion": "2.0",
+								"settings": {"commandToExecute": "echo one"}
+							}
+						},
+						{
+							"name": "ApplicationHealthExtension",
+							"properties": {
+								"publisher": "Microsoft.ManagedServices",
+								"type": "ApplicationHealthLinux",
+								"typeHandlerVersion": "1.0",
+								"settings": {"protocol": "http", "port": 80, "requestPath": "/health"}
+							}
+						}
+					]
+				}
+			}
+		}
+	}`
+	instanceView := `{"virtualMachine": {"statusesSummary": [{"code": "ProvisioningState/succeeded", "count": 2}]}}`
+
+	vmssc := compute.NewVirtualMachineScaleSetsClient("00000000-0000-0000-0000-000000000000")
+	vmssc.Sender = autorest.SenderFunc(func(r *http.Request) (*http.Response, error) {
+		body := scaleSet
+		if strings.HasSuffix(r.URL.Path, "/instanceView") {
+			body = instanceView
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			Request:    r,
+		}, nil
+	})
+
+	d := dataSourceArmVirtualMachineScaleSet().TestResourceData()
+	d.Set("name", "acctvmss")
+	d.Set("resource_group_name", "acctestrg")
+
+	if err := dataSourceArmVirtualMachineScaleSetRead(d, &ArmClient{vmScaleSetClient: vmssc}); err != nil {
+		t.Fatalf("Error reading the scale set: %s", err)
+	}
+
+	attributes := d.State().Attributes
+	expected := map[string]string{
+		"instances":                              "2",
+		"instance_provisioning_states.succeeded": "2",
+		"os_profile.#":                           "1",
+		"os_profile_secrets.#":                   "1",
+		"os_profile_linux_config.#":              "1",
+		"storage_profile_os_disk.#":              "1",
+		"extension.#":                            "1",
+		"application_health_extension.#":         "1",
+	}
+	for k, v := range expected {
+		if attributes[k] != v {
+			t.Fatalf("Expected %s to be %q, got %q", k, v, attributes[k])
+		}
+	}
+
+	suffixes := map[string]string{
+		".computer_name_prefix": "testvm",
+		".admin_username":       "myadmin",
+		".caching":              "ReadWrite",
+		".type_handler_version": "2.0",
+		".request_path":         "/health",
+		".certificate_url":      "https://acctkv.vault.azure.net/secrets/cert/1",
+		".key_data":             "ssh-rsa AAAA",
+	}
+	for suffix, v := range suffixes {
+		found := false
+		for k, actual := range attributes {
+			if strings.HasSuffix(k, suffix) && actual == v {
+				found = true
+			}
+		}
+
+		if !found {
+			t.Fatalf("Expected an attribute ending in %s with value %q, got %#v", suffix, v, attributes)
+		}
+	}
+
+	for k := range attributes {
+		if strings.HasSuffix(k, ".admin_password") || strings.HasSuffix(k, ".custom_data") {
+			t.Fatalf("Expected the data source not to expose %s", k)
+		}
+	}
+}
+
+// testCheckAzureRMVirtualMachineScaleSetDataSourceMatches verifies that every
+// attribute exposed by the data source has the same value as the resource.
+func testCheckAzureRMVirtualMachineScaleSetDataSourceMatches(dataSourceName string, resourceName string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		ds, ok := s.RootModule().Resources[dataSourceName]
+		if !ok {
+			return fmt.Errorf("Not found: %s", dataSourceName)
+		}
+
+		rs, ok := s.RootModule().Resources[resourceName]
+		if !ok {
+			return fmt.Errorf("Not found: %s", resourceName)
+		}
+
+		if ds.Primary.ID != rs.Primary.ID {
+			return fmt.Errorf("Data source ID %q does not match resource ID %q", ds.Primary.ID, rs.Primary.ID)
+		}
+
+		for k, v := range ds.Primary.Attributes {
+			if k == "id" {
+				continue
+			}
+
+			if rs.Primary.Attributes[k] != v {
+				return fmt.Errorf("Data source attribute %q is %q, but the resource has %q", k, v, rs.Primary.Attributes[k])
+			}
+		}
+
+		return nil
+	}
+}
+
+var testAccDataSourceAzureRMVirtualMachineScaleSet_basic = `
+data "azurerm_virtual_machine_scale_set" "test" {
+  name                = "${azurerm_virtual_machine_scale_set.test.name}"
+  resource_group_name = "${azurerm_virtual_machine_scale_set.test.resource_group_name}"
+}
+`
@@ -3,7 +3,9 @@ package azurerm
 import (
 	"fmt"
 	"reflect"
+	"regexp"
 	"strings"
+	"time"
 
 	"sync"
 
@@ -42,6 +44,24 @@ func Provider() terraform.ResourceProvider {
 				Required:    true,
 				DefaultFunc: schema.EnvDefaultFunc("ARM_TENANT_ID", ""),
 			},
+
+			"operation_timeout": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("ARM_OPERATION_TIMEOUT", "20m"),
+				ValidateFunc: validateAzureRMDuration,
+			},
+
+			"polling_interval": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("ARM_POLLING_INTERVAL", "10s"),
+				ValidateFunc: validateAzureRMDuration,
+			},
+		},
+
+		DataSourcesMap: map[string]*schema.Resource{
+			"azurerm_virtual_machine_scale_set": dataSourceArmVirtualMachineScaleSet(),
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
@@ -95,9 +115,19 @@ type Config struct {
 	ClientSecret   string
 	TenantID       string
 
+	// OperationTimeout and PollingInterval are the defaults used when waiting
+	// on long-running operations which do not set their own.
+	OperationTimeout time.Duration
+	PollingInterval  time.Duration
+
 	validateCredentialsOnce sync.Once
 }
 
+const (
+	defaultAzureRMOperationTimeout = 20 * time.Minute
+	defaultAzureRMPollingInterval  = 10 * time.Second
+)
+
 func (c *Config) validate() error {
 	var err *multierror.Error
 
@@ -125,6 +155,10 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 		TenantID:       d.Get("tenant_id").(string),
 	}
 
+	// Both durations have already been validated
+	config.OperationTimeout, _ = time.ParseDuration(d.Get("operation_timeout").(string))
+	config.PollingInterval, _ = time.ParseDuration(d.Get("polling_interval").(string))
+
 	if err := config.validate(); err != nil {
 		return nil, err
 	}
@@ -190,15 +224,97 @@ func registerAzureResourceProvidersWithSubscription(client *riviera.Client) erro
 	return err
 }
 
+// azureRMLocations are the canonical names of the Azure regions known to this
+// provider, as used and returned by the Azure API. Azure adds regions over
+// time, so a location missing from this list only produces a warning.
+var azureRMLocations = []string{
+	"australiaeast",
+	"australiasoutheast",
+	"brazilsouth",
+	"canadacentral",
+	"canadaeast",
+	"centralindia",
+	"centralus",
+	"chinaeast",
+	"chinanorth",
+	"eastasia",
+	"eastus",
+	"eastus2",
+	"germanycentral",
+	"germanynortheast",
+	"japaneast",
+	"japanwest",
+	"koreacentral",
+	"koreasouth",
+	"northcentralus",
+	"northeurope",
+	"southcentralus",
+	"southeastasia",
+	"southindia",
+	"uksouth",
+	"ukwest",
+	"usgoviowa",
+	"usgovvirginia",
+	"westcentralus",
+	"westeurope",
+	"westindia",
+	"westus",
+	"westus2",
+}
+
 // azureRMNormalizeLocation is a function which normalises human-readable region/location
 // names (e.g. "West US") to the values used and returned by the Azure API (e.g. "westus").
 // In state we track the API internal version as it is easier to go from the human form
 // to the canonical form than the other way around.
 func azureRMNormalizeLocation(location interface{}) string {
 	input := location.(string)
-	return strings.Replace(strings.ToLower(input), " ", "", -1)
+	return strings.Replace(strings.ToLower(strings.TrimSpace(input)), " ", "", -1)
 }
 
+// validateAzureRMDuration checks that a value is a positive duration such as
+// "30s" or "20m".
+func validateAzureRMDuration(v interface{}, k string) (ws []string, errors []error) {
+	value := v.(string)
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%q must be a duration such as \"30s\" or \"20m\", got %q: %s", k, value, err))
+		return
+	}
+
+	if duration <= 0 {
+		errors = append(errors, fmt.Errorf("%q must be greater than zero, got %q", k, value))
+	}
+	return
+}
+
+// validateAzureRMLocation checks that a location, in either its display form
+// (e.g. "West US 2") or its canonical form (e.g. "westus2"), has the shape of
+// a region name, rejecting anything which does not. A well-formed region
+// missing from azureRMLocations only produces a warning rather than an error:
+// Azure ships new regions before this list is updated, and rejecting them
+// would block their use until the next provider release. The API still
+// rejects a region which does not exist.
+func validateAzureRMLocation(v interface{}, k string) (ws []string, errors []error) {
+	location := azureRMNormalizeLocation(v)
+
+	if !azureRMLocationRegexp.MatchString(location) {
+		errors = append(errors, fmt.Errorf("%q must be an Azure region such as \"West US 2\" or \"westus2\", got %q", k, v.(string)))
+		return
+	}
+
+	for _, known := range azureRMLocations {
+		if location == known {
+			return
+		}
+	}
+
+	ws = append(ws, fmt.Sprintf("%q is not a region known to this provider, got %q. If Azure does not recognise it either, applying will fail", k, v.(string)))
+	return
+}
+
+var azureRMLocationRegexp = regexp.MustCompile("^[a-z0-9]+$")
+
 // armMutexKV is the instance of MutexKV for ARM resources
 var armMutexKV = mutexkv.NewMutexKV()
 
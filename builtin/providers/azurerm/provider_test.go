@@ -1,8 +1,11 @@
 package azurerm
 
 import (
+	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/terraform"
@@ -38,3 +41,111 @@ func testAccPreCheck(t *testing.T) {
 		t.Fatal("ARM_SUBSCRIPTION_ID, ARM_CLIENT_ID, ARM_CLIENT_SECRET and ARM_TENANT_ID must be set for acceptance tests")
 	}
 }
+
+func TestProvider_operationDefaults(t *testing.T) {
+	p := Provider().(*schema.Provider)
+
+	for _, key := range []string{"operation_timeout", "polling_interval"} {
+		env := fmt.Sprintf("ARM_%s", strings.ToUpper(key))
+		if previous, ok := os.LookupEnv(env); ok {
+			defer os.Setenv(env, previous)
+		}
+		os.Unsetenv(env)
+	}
+
+	defaults := map[string]time.Duration{
+		"operation_timeout": defaultAzureRMOperationTimeout,
+		"polling_interval":  defaultAzureRMPollingInterval,
+	}
+	for key, expected := range defaults {
+		v, err := p.Schema[key].DefaultValue()
+		if err != nil {
+			t.Fatalf("Error reading the default %s: %s", key, err)
+		}
+
+		if actual, err := time.ParseDuration(v.(string)); err != nil || actual != expected {
+			t.Fatalf("Expected the default %s to be %s, got %v", key, expected, v)
+		}
+	}
+}
+
+func TestValidateAzureRMDuration(t *testing.T) {
+	testCases := []struct {
+		input       string
+		shouldError bool
+	}{
+		{"10s", false},
+		{"20m", false},
+		{"1h30m", false},
+		{"0s", true},
+		{"-5m", true},
+		{"20", true},
+		{"twenty minutes", true},
+	}
+
+	for _, test := range testCases {
+		_, es := validateAzureRMDuration(test.input, "operation_timeout")
+
+		if test.shouldError && len(es) == 0 {
+			t.Fatalf("Expected validating %q to fail", test.input)
+		}
+
+		if !test.shouldError && len(es) > 0 {
+			t.Fatalf("Expected validating %q to pass: %v", test.input, es)
+		}
+	}
+}
+
+func TestAzureRMNormalizeLocation(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"West US", "westus"},
+		{"West US 2", "westus2"},
+		{"westus2", "westus2"},
+		{"WestUS2", "westus2"},
+		{" North Europe ", "northeurope"},
+		{"UK South", "uksouth"},
+	}
+
+	for _, test := range testCases {
+		if actual := azureRMNormalizeLocation(test.input); actual != test.expected {
+			t.Fatalf("Expected %q to normalize to %q, got %q", test.input, test.expected, actual)
+		}
+	}
+}
+
+func TestValidateAzureRMLocation(t *testing.T) {
+	testCases := []struct {
+		input         string
+		shouldError   bool
+		shouldWarning bool
+	}{
+		{"West US 2", false, false},
+		{"westus2", false, false},
+		{"Southeast Asia", false, false},
+		{"US Gov Virginia", false, false},
+		{"West US 3 Extended", false, true},
+		{"Mars Central", false, true},
+		{"West-US", true, false},
+		{"  ", true, false},
+		{"", true, false},
+	}
+
+	for _, test := range testCases {
+		ws, es := validateAzureRMLocation(test.input, "location")
+
+		if test.shouldError && len(es) == 0 {
+			t.Fatalf("Expected validating location %q to fail", test.input)
+		}
+
+		if !test.shouldError && len(es) > 0 {
+			t.Fatalf("Expected validating location %q to pass: %v", test.input, es)
+		}
+
+		if test.shouldWarning != (len(ws) > 0) {
+			t.Fatalf("Expected a warning for location %q to be %t, got %v", test.input, test.shouldWarning, ws)
+		}
+	}
+}
@@ -2,11 +2,24 @@ package azurerm
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/Azure/azure-sdk-for-go/arm/compute"
+	"github.com/Azure/go-autorest/autorest"
 	"github.com/hashicorp/terraform/helper/hashcode"
 	"github.com/hashicorp/terraform/helper/resource"
 	"github.com/hashicorp/terraform/helper/schema"
@@ -16,8 +29,14 @@ func resourceArmVirtualMachineScaleSet() *schema.Resource {
 	return &schema.Resource{
 		Create: resourceArmVirtualMachineScaleSetCreate,
 		Read:   resourceArmVirtualMachineScaleSetRead,
-		Update: resourceArmVirtualMachineScaleSetCreate,
+		Update: resourceArmVirtualMachineScaleSetUpdate,
 		Delete: resourceArmVirtualMachineScaleSetDelete,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+
+		SchemaVersion: 1,
+		MigrateState:  resourceArmVirtualMachineScaleSetMigrateState,
 
 		Schema: map[string]*schema.Schema{
 			"name": &schema.Schema{
@@ -27,10 +46,11 @@ func resourceArmVirtualMachineScaleSet() *schema.Resource {
 			},
 
 			"location": &schema.Schema{
-				Type:      schema.TypeString,
-				Required:  true,
-				ForceNew:  true,
-				StateFunc: azureRMNormalizeLocation,
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				StateFunc:    azureRMNormalizeLocation,
+				ValidateFunc: validateAzureRMLocation,
 			},
 
 			"resource_group_name": &schema.Schema{
@@ -51,14 +71,16 @@ func resourceArmVirtualMachineScaleSet() *schema.Resource {
 						},
 
 						"tier": &schema.Schema{
-							Type:     schema.TypeString,
-							Optional: true,
-							Computed: true,
+							Type:         schema.TypeString,
+							Optional:     true,
+							Computed:     true,
+							ValidateFunc: validateArmVirtualMachineScaleSetSkuTier,
 						},
 
 						"capacity": &schema.Schema{
-							Type:     schema.TypeInt,
-							Required: true,
+							Type:         schema.TypeInt,
+							Required:     true,
+							ValidateFunc: validateArmVirtualMachineScaleSetCapacity,
 						},
 					},
 				},
@@ -66,8 +88,30 @@ func resourceArmVirtualMachineScaleSet() *schema.Resource {
 			},
 
 			"upgrade_policy_mode": &schema.Schema{
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateArmVirtualMachineScaleSetUpgradePolicyMode,
+			},
+
+			"overprovision": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  true,
+			},
+
+			"provisioning_state": &schema.Schema{
 				Type:     schema.TypeString,
-				Required: true,
+				Computed: true,
+			},
+
+			"instances": &schema.Schema{
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+
+			"instance_provisioning_states": &schema.Schema{
+				Type:     schema.TypeMap,
+				Computed: true,
 			},
 
 			"os_profile": &schema.Schema{
@@ -79,21 +123,26 @@ func resourceArmVirtualMachineScaleSet() *schema.Resource {
 						"computer_name_prefix": &schema.Schema{
 							Type:     schema.TypeString,
 							Required: true,
+							ForceNew: true,
 						},
 
 						"admin_username": &schema.Schema{
 							Type:     schema.TypeString,
 							Required: true,
+							ForceNew: true,
 						},
 
 						"admin_password": &schema.Schema{
-							Type:     schema.TypeString,
-							Required: true,
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validateArmVirtualMachineScaleSetAdminPassword,
 						},
 
 						"custom_data": &schema.Schema{
-							Type:     schema.TypeString,
-							Optional: true,
+							Type:         schema.TypeString,
+							Optional:     true,
+							StateFunc:    normalizeArmVirtualMachineScaleSetCustomData,
+							ValidateFunc: validateArmVirtualMachineScaleSetCustomData,
 						},
 					},
 				},
@@ -125,6 +174,7 @@ func resourceArmVirtualMachineScaleSet() *schema.Resource {
 									},
 								},
 							},
+							Set: resourceArmVirtualMachineScaleSetVaultCertificatesHash,
 						},
 					},
 				},
@@ -233,6 +283,11 @@ func resourceArmVirtualMachineScaleSet() *schema.Resource {
 							Required: true,
 						},
 
+						"id": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+
 						"primary": &schema.Schema{
 							Type:     schema.TypeBool,
 							Required: true,
@@ -260,6 +315,25 @@ func resourceArmVirtualMachineScaleSet() *schema.Resource {
 										Elem:     &schema.Schema{Type: schema.TypeString},
 										Set:      schema.HashString,
 									},
+
+									"application_gateway_backend_address_pool_ids": &schema.Schema{
+										Type:     schema.TypeSet,
+										Optional: true,
+										Computed: true,
+										Elem:     &schema.Schema{Type: schema.TypeString},
+										Set:      schema.HashString,
+									},
+
+									"load_balancer_inbound_nat_pool_ids": &schema.Schema{
+										Type:     schema.TypeSet,
+										Optional: true,
+										Computed: true,
+										Elem: &schema.Schema{
+											Type:         schema.TypeString,
+											ValidateFunc: validateArmLoadBalancerInboundNatPoolId,
+										},
+										Set: schema.HashString,
+									},
 								},
 							},
 						},
@@ -277,35 +351,44 @@ func resourceArmVirtualMachineScaleSet() *schema.Resource {
 						"name": &schema.Schema{
 							Type:     schema.TypeString,
 							Required: true,
+							ForceNew: true,
 						},
 
 						"image": &schema.Schema{
 							Type:     schema.TypeString,
 							Optional: true,
 							Computed: true,
+							ForceNew: true,
 						},
 
 						"vhd_containers": &schema.Schema{
 							Type:     schema.TypeSet,
 							Required: true,
-							Elem:     &schema.Schema{Type: schema.TypeString},
-							Set:      schema.HashString,
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: validateArmVirtualMachineScaleSetVhdContainer,
+							},
+							Set: schema.HashString,
 						},
 
 						"caching": &schema.Schema{
-							Type:     schema.TypeString,
-							Required: true,
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validateArmVirtualMachineScaleSetCaching,
 						},
 
 						"os_type": &schema.Schema{
-							Type:     schema.TypeString,
-							Optional: true,
-							Computed: true,
+							Type:         schema.TypeString,
+							Optional:     true,
+							Computed:     true,
+							ForceNew:     true,
+							ValidateFunc: validateArmVirtualMachineScaleSetOsType,
 						},
 
 						"create_option": &schema.Schema{
 							Type:     schema.TypeString,
 							Required: true,
+							ForceNew: true,
 						},
 					},
 				},
@@ -343,7 +426,102 @@ func resourceArmVirtualMachineScaleSet() *schema.Resource {
 				Set: resourceArmVirtualMachineScaleSetStorageProfileImageReferenceHash,
 			},
 
-			"tags": tagsSchema(),
+			"extension": &schema.Schema{
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": &schema.Schema{
+							Type:     schema.TypeString,
+							Required: true,
+						},
+
+						"publisher": &schema.Schema{
+							Type:     schema.TypeString,
+							Required: true,
+						},
+
+						"type": &schema.Schema{
+							Type:     schema.TypeString,
+							Required: true,
+						},
+
+						"type_handler_version": &schema.Schema{
+							Type:     schema.TypeString,
+							Required: true,
+						},
+
+						"auto_upgrade_minor_version": &schema.Schema{
+							Type:     schema.TypeBool,
+							Optional: true,
+						},
+
+						"settings": &schema.Schema{
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validateJsonString,
+						},
+
+						"protected_settings": &schema.Schema{
+							Type:         schema.TypeString,
+							Optional:     true,
+							Sensitive:    true,
+							ValidateFunc: validateJsonString,
+							StateFunc:    normalizeJson,
+						},
+
+						"protected_settings_hash": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+				Set: resourceArmVirtualMachineScaleSetExtensionHash,
+			},
+
+			"application_health_extension": &schema.Schema{
+				Type:     schema.TypeSet,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"protocol": &schema.Schema{
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validateArmVirtualMachineScaleSetApplicationHealthProtocol,
+						},
+
+						"port": &schema.Schema{
+							Type:     schema.TypeInt,
+							Required: true,
+						},
+
+						"request_path": &schema.Schema{
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+					},
+				},
+				Set: resourceArmVirtualMachineScaleSetApplicationHealthExtensionHash,
+			},
+
+			"polling_interval": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateArmVirtualMachineScaleSetPollingInterval,
+			},
+
+			"skip_create_wait": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+
+			"tags": &schema.Schema{
+				Type:         schema.TypeMap,
+				Optional:     true,
+				ValidateFunc: validateArmVirtualMachineScaleSetTags,
+			},
 		},
 	}
 }
@@ -383,15 +561,28 @@ func resourceArmVirtualMachineScaleSetCreate(d *schema.ResourceData, meta interf
 		return err
 	}
 
+	networkProfile, err := expandAzureRmVirtualMachineScaleSetNetworkProfile(d)
+	if err != nil {
+		return err
+	}
+
+	extensionProfile, err := expandAzureRMVirtualMachineScaleSetExtensions(d)
+	if err != nil {
+		return err
+	}
+
 	updatePolicy := d.Get("upgrade_policy_mode").(string)
+	overprovision := d.Get("overprovision").(bool)
 	scaleSetProps := compute.VirtualMachineScaleSetProperties{
 		UpgradePolicy: &compute.UpgradePolicy{
 			Mode: compute.UpgradeMode(updatePolicy),
 		},
+		OverProvision: &overprovision,
 		VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
-			NetworkProfile: expandAzureRmVirtualMachineScaleSetNetworkProfile(d),
-			StorageProfile: &storageProfile,
-			OsProfile:      osProfile,
+			NetworkProfile:   networkProfile,
+			StorageProfile:   &storageProfile,
+			OsProfile:        osProfile,
+			ExtensionProfile: extensionProfile,
 		},
 	}
 
@@ -402,8 +593,16 @@ func resourceArmVirtualMachineScaleSetCreate(d *schema.ResourceData, meta interf
 		Sku:        sku,
 		Properties: &scaleSetProps,
 	}
-	_, vmErr := vmScaleSetClient.CreateOrUpdate(resGroup, name, scaleSetParams, make(chan struct{}))
-	if vmErr != nil {
+	wait := !(d.IsNewResource() && d.Get("skip_create_wait").(bool))
+	vmScaleSetClient.PollingDelay = resourceArmVirtualMachineScaleSetPollingInterval(d, client)
+	if vmErr := virtualMachineScaleSetCreateOrUpdate(vmScaleSetClient, resGroup, name, scaleSetParams, wait, virtualMachineScaleSetOperationTimeout(client)); vmErr != nil {
+		// A scale set which timed out or failed to provision still exists in
+		// Azure, so keep it in state to be tainted rather than lose track of it
+		if d.Id() == "" {
+			if read, err := vmScaleSetClient.Get(resGroup, name); err == nil && read.ID != nil {
+				d.SetId(*read.ID)
+			}
+		}
 		return vmErr
 	}
 
@@ -420,6 +619,246 @@ func resourceArmVirtualMachineScaleSetCreate(d *schema.ResourceData, meta interf
 	return resourceArmVirtualMachineScaleSetRead(d, meta)
 }
 
+// virtualMachineScaleSetCreateOrUpdate sends a scale set to Azure. When wait is
+// false it returns as soon as Azure has accepted the request, without polling
+// the long-running operation until the scale set has been provisioned. When
+// waiting, a warning is logged once half of timeout has elapsed and polling is
+// cancelled once all of it has.
+func virtualMachineScaleSetCreateOrUpdate(client compute.VirtualMachineScaleSetsClient, resourceGroupName string, name string, parameters compute.VirtualMachineScaleSet, wait bool, timeout time.Duration) error {
+	if wait {
+		start := time.Now()
+		warning := time.AfterFunc(timeout/2, func() {
+			state := "unknown"
+			if res, err := client.Get(resourceGroupName, name); err == nil && res.Properties != nil && res.Properties.ProvisioningState != nil {
+				state = *res.Properties.ProvisioningState
+			}
+			log.Printf("[WARN] Virtual Machine Scale Set '%s' (RG: '%s') is still in provisioning state %q after %s, more than half of the %s timeout", name, resourceGroupName, state, time.Since(start), timeout)
+		})
+		defer warning.Stop()
+
+		cancel := make(chan struct{})
+		timer := time.AfterFunc(timeout, func() { close(cancel) })
+		defer timer.Stop()
+
+		_, err := client.CreateOrUpdate(resourceGroupName, name, parameters, cancel)
+		if err != nil {
+			select {
+			case <-cancel:
+				return fmt.Errorf("Timed out after %s waiting for Azure ARM Virtual Machine Scale Set %s: %s", timeout, name, err)
+			default:
+			}
+		}
+		return err
+	}
+
+	req, err := client.CreateOrUpdatePreparer(resourceGroupName, name, parameters, make(chan struct{}))
+	if err != nil {
+		return fmt.Errorf("Error preparing the request for Azure ARM Virtual Machine Scale Set %s: %s", name, err)
+	}
+
+	resp, err := autorest.SendWithSender(client, req)
+	if err != nil {
+		return fmt.Errorf("Error sending the request for Azure ARM Virtual Machine Scale Set %s: %s", name, err)
+	}
+
+	_, err = client.CreateOrUpdateResponder(resp)
+	return err
+}
+
+func resourceArmVirtualMachineScaleSetUpdate(d *schema.ResourceData, meta interface{}) error {
+	if resourceArmVirtualMachineScaleSetOnlyLocalFieldsChanged(d) {
+		return resourceArmVirtualMachineScaleSetRead(d, meta)
+	}
+
+	if resourceArmVirtualMachineScaleSetOnlyExtensionsChanged(d) {
+		return resourceArmVirtualMachineScaleSetUpdateExtensions(d, meta)
+	}
+
+	if !resourceArmVirtualMachineScaleSetOnlyCapacityChanged(d) {
+		return resourceArmVirtualMachineScaleSetCreate(d, meta)
+	}
+
+	vmScaleSetClient := meta.(*ArmClient).vmScaleSetClient
+
+	id, err := parseAzureResourceID(d.Id())
+	if err != nil {
+		return err
+	}
+	resGroup := id.ResourceGroup
+	name := id.getPathValue("virtualMachineScaleSets")
+
+	existing, err := virtualMachineScaleSetGetForUpdate(vmScaleSetClient, resGroup, name)
+	if err != nil {
+		return err
+	}
+
+	scaleSetParams, err := expandAzureRmVirtualMachineScaleSetCapacityUpdate(d, existing)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Scaling Azure ARM Virtual Machine Scale Set %s (resource group %s) to %d instances", name, resGroup, *scaleSetParams.Sku.Capacity)
+	vmScaleSetClient.PollingDelay = resourceArmVirtualMachineScaleSetPollingInterval(d, meta.(*ArmClient))
+	err = virtualMachineScaleSetCreateOrUpdate(vmScaleSetClient, resGroup, name, *scaleSetParams, true, virtualMachineScaleSetOperationTimeout(meta.(*ArmClient)))
+	if err != nil {
+		return fmt.Errorf("Error scaling Azure ARM Virtual Machine Scale Set %s: %s", name, err)
+	}
+
+	return resourceArmVirtualMachineScaleSetRead(d, meta)
+}
+
+// resourceArmVirtualMachineScaleSetUpdateExtensions replaces only the
+// extension profile of the scale set, sending the rest of it as Azure has it.
+func resourceArmVirtualMachineScaleSetUpdateExtensions(d *schema.ResourceData, meta interface{}) error {
+	vmScaleSetClient := meta.(*ArmClient).vmScaleSetClient
+
+	id, err := parseAzureResourceID(d.Id())
+	if err != nil {
+		return err
+	}
+	resGroup := id.ResourceGroup
+	name := id.getPathValue("virtualMachineScaleSets")
+
+	existing, err := virtualMachineScaleSetGetForUpdate(vmScaleSetClient, resGroup, name)
+	if err != nil {
+		return err
+	}
+
+	scaleSetParams, err := expandAzureRmVirtualMachineScaleSetExtensionsUpdate(d, existing)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[INFO] Updating the extensions of Azure ARM Virtual Machine Scale Set %s (resource group %s)", name, resGroup)
+	vmScaleSetClient.PollingDelay = resourceArmVirtualMachineScaleSetPollingInterval(d, meta.(*ArmClient))
+	err = virtualMachineScaleSetCreateOrUpdate(vmScaleSetClient, resGroup, name, *scaleSetParams, true, virtualMachineScaleSetOperationTimeout(meta.(*ArmClient)))
+	if err != nil {
+		return fmt.Errorf("Error updating the extensions of Azure ARM Virtual Machine Scale Set %s: %s", name, err)
+	}
+
+	return resourceArmVirtualMachineScaleSetRead(d, meta)
+}
+
+// virtualMachineScaleSetGetForUpdate reads the scale set as Azure has it. This
+// API version can only update a scale set with a full PUT, so updates which
+// change part of a scale set start from its current definition.
+func virtualMachineScaleSetGetForUpdate(client compute.VirtualMachineScaleSetsClient, resourceGroupName string, name string) (*compute.VirtualMachineScaleSet, error) {
+	resp, err := client.Get(resourceGroupName, name)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading Azure ARM Virtual Machine Scale Set %s before updating it: %s", name, err)
+	}
+
+	if resp.Properties == nil || resp.Properties.VirtualMachineProfile == nil {
+		return nil, fmt.Errorf("Error reading Azure ARM Virtual Machine Scale Set %s before updating it: the API returned no virtual machine profile", name)
+	}
+
+	scaleSet := resp
+	scaleSet.Response = autorest.Response{}
+	scaleSet.Properties.ProvisioningState = nil
+	return &scaleSet, nil
+}
+
+// resourceArmVirtualMachineScaleSetOnlyExtensionsChanged reports whether the
+// extensions are the only thing which differs between state and config, in
+// which case only the extension profile needs to be sent to Azure.
+func resourceArmVirtualMachineScaleSetOnlyExtensionsChanged(d *schema.ResourceData) bool {
+	extensionFields := map[string]bool{
+		"extension":                    true,
+		"application_health_extension": true,
+	}
+
+	if !resourceArmVirtualMachineScaleSetHasChange(d, "extension") && !resourceArmVirtualMachineScaleSetHasChange(d, "application_health_extension") {
+		return false
+	}
+
+	for k := range resourceArmVirtualMachineScaleSet().Schema {
+		if !extensionFields[k] && !virtualMachineScaleSetLocalFields[k] && resourceArmVirtualMachineScaleSetHasChange(d, k) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// resourceArmVirtualMachineScaleSetOnlyCapacityChanged reports whether the
+// capacity of the sku is the only thing which differs between state and
+// config, in which case the scale set can be scaled in place rather than
+// sending the full definition back through CreateOrUpdate.
+func resourceArmVirtualMachineScaleSetOnlyCapacityChanged(d *schema.ResourceData) bool {
+	if !resourceArmVirtualMachineScaleSetHasChange(d, "sku") {
+		return false
+	}
+
+	for k := range resourceArmVirtualMachineScaleSet().Schema {
+		if k != "sku" && !virtualMachineScaleSetLocalFields[k] && resourceArmVirtualMachineScaleSetHasChange(d, k) {
+			return false
+		}
+	}
+
+	o, n := d.GetChange("sku")
+	oldSkus := o.(*schema.Set).List()
+	newSkus := n.(*schema.Set).List()
+	if len(oldSkus) != 1 || len(newSkus) != 1 {
+		return false
+	}
+
+	oldSku := oldSkus[0].(map[string]interface{})
+	newSku := newSkus[0].(map[string]interface{})
+
+	return oldSku["name"] == newSku["name"] && oldSku["tier"] == newSku["tier"]
+}
+
+// virtualMachineScaleSetLocalFields are the fields which only affect how
+// Terraform manages the scale set, and are never sent to Azure.
+var virtualMachineScaleSetLocalFields = map[string]bool{
+	"polling_interval": true,
+	"skip_create_wait": true,
+}
+
+// resourceArmVirtualMachineScaleSetOnlyLocalFieldsChanged reports whether the
+// only changes are to fields which are never sent to Azure.
+func resourceArmVirtualMachineScaleSetOnlyLocalFieldsChanged(d *schema.ResourceData) bool {
+	for k := range resourceArmVirtualMachineScaleSet().Schema {
+		if !virtualMachineScaleSetLocalFields[k] && resourceArmVirtualMachineScaleSetHasChange(d, k) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// resourceArmVirtualMachineScaleSetHasChange reports whether the value of key
+// differs between state and config. Unlike d.HasChange it compares sets by
+// their contents, so that sets nested within sets, such as the vhd_containers
+// of storage_profile_os_disk, are not always reported as changed.
+func resourceArmVirtualMachineScaleSetHasChange(d *schema.ResourceData, key string) bool {
+	o, n := d.GetChange(key)
+	return !reflect.DeepEqual(virtualMachineScaleSetComparableValue(o), virtualMachineScaleSetComparableValue(n))
+}
+
+// virtualMachineScaleSetComparableValue replaces every set within v with the
+// list of its elements, which is ordered by hash code.
+func virtualMachineScaleSetComparableValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case *schema.Set:
+		return virtualMachineScaleSetComparableValue(value.List())
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, item := range value {
+			result[i] = virtualMachineScaleSetComparableValue(item)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			result[k] = virtualMachineScaleSetComparableValue(item)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
 func resourceArmVirtualMachineScaleSetRead(d *schema.ResourceData, meta interface{}) error {
 	vmScaleSetClient := meta.(*ArmClient).vmScaleSetClient
 
@@ -428,7 +867,7 @@ func resourceArmVirtualMachineScaleSetRead(d *schema.ResourceData, meta interfac
 		return err
 	}
 	resGroup := id.ResourceGroup
-	name := id.Path["virtualMachineScaleSets"]
+	name := id.getPathValue("virtualMachineScaleSets")
 
 	resp, err := vmScaleSetClient.Get(resGroup, name)
 	if resp.StatusCode == http.StatusNotFound {
@@ -442,14 +881,21 @@ func resourceArmVirtualMachineScaleSetRead(d *schema.ResourceData, meta interfac
 
 	d.Set("location", resp.Location)
 	d.Set("name", resp.Name)
+	d.Set("resource_group_name", resGroup)
 
 	if err := d.Set("sku", flattenAzureRmVirtualMachineScaleSetSku(resp.Sku)); err != nil {
 		return fmt.Errorf("[DEBUG] Error setting Virtual Machine Scale Set Sku error: %#v", err)
 	}
 
 	d.Set("upgrade_policy_mode", resp.Properties.UpgradePolicy.Mode)
+	if resp.Properties.OverProvision != nil {
+		d.Set("overprovision", *resp.Properties.OverProvision)
+	}
+	if resp.Properties.ProvisioningState != nil {
+		d.Set("provisioning_state", *resp.Properties.ProvisioningState)
+	}
 
-	if err := d.Set("os_profile", flattenAzureRMVirtualMachineScaleSetOsProfile(resp.Properties.VirtualMachineProfile.OsProfile)); err != nil {
+	if err := d.Set("os_profile", flattenAzureRMVirtualMachineScaleSetOsProfile(d, resp.Properties.VirtualMachineProfile.OsProfile)); err != nil {
 		return fmt.Errorf("[DEBUG] Error setting Virtual Machine Scale Set OS Profile error: %#v", err)
 	}
 
@@ -485,7 +931,41 @@ func resourceArmVirtualMachineScaleSetRead(d *schema.ResourceData, meta interfac
 		return fmt.Errorf("[DEBUG] Error setting Virtual Machine Scale Set Storage Profile OS Disk error: %#v", err)
 	}
 
-	flattenAndSetTags(d, resp.Tags)
+	if resp.Properties.VirtualMachineProfile.ExtensionProfile != nil {
+		extension, err := flattenAzureRmVirtualMachineScaleSetExtensionProfile(d, resp.Properties.VirtualMachineProfile.ExtensionProfile)
+		if err != nil {
+			return fmt.Errorf("[DEBUG] Error flattening Virtual Machine Scale Set Extension Profile error: %#v", err)
+		}
+		if err := d.Set("extension", extension); err != nil {
+			return fmt.Errorf("[DEBUG] Error setting Virtual Machine Scale Set Extension Profile error: %#v", err)
+		}
+
+		if err := d.Set("application_health_extension", flattenAzureRmVirtualMachineScaleSetApplicationHealthExtension(resp.Properties.VirtualMachineProfile.ExtensionProfile)); err != nil {
+			return fmt.Errorf("[DEBUG] Error setting Virtual Machine Scale Set Application Health Extension error: %#v", err)
+		}
+	}
+
+	// The instance view only fills in informational fields, so failing to read
+	// it does not fail the refresh
+	instanceView, err := vmScaleSetClient.GetInstanceView(resGroup, name)
+	if instanceView.Response.Response != nil && instanceView.StatusCode == http.StatusNotFound {
+		log.Printf("[INFO] AzureRM Virtual Machine Scale Set (%s) Not Found. Removing from State", name)
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		log.Printf("[WARN] Error making Read request on the instance view of Azure Virtual Machine Scale Set %s, leaving instances and instance_provisioning_states unchanged: %s", name, err)
+	} else {
+		instances, provisioningStates := flattenAzureRmVirtualMachineScaleSetInstanceView(instanceView)
+		d.Set("instances", instances)
+		if err := d.Set("instance_provisioning_states", provisioningStates); err != nil {
+			return fmt.Errorf("[DEBUG] Error setting Virtual Machine Scale Set Instance Provisioning States error: %#v", err)
+		}
+	}
+
+	if resp.Tags != nil {
+		d.Set("tags", flattenAzureRmVirtualMachineScaleSetTags(resp.Tags, d.Get("tags").(map[string]interface{})))
+	}
 
 	return nil
 }
@@ -498,17 +978,127 @@ func resourceArmVirtualMachineScaleSetDelete(d *schema.ResourceData, meta interf
 		return err
 	}
 	resGroup := id.ResourceGroup
-	name := id.Path["virtualMachineScaleSets"]
+	name := id.getPathValue("virtualMachineScaleSets")
 
 	_, err = vmScaleSetClient.Delete(resGroup, name, make(chan struct{}))
+	if err != nil {
+		return err
+	}
 
-	return err
+	log.Printf("[DEBUG] Waiting for Virtual Machine Scale Set (%s) to be deleted", name)
+	stateConf := virtualMachineScaleSetDeleteStateChangeConf(d, meta.(*ArmClient), resGroup, name)
+	return waitForVirtualMachineScaleSetDeletion(stateConf, meta.(*ArmClient), resGroup, name)
 }
 
-func flattenAzureRmVirtualMachineScaleSetOsProfileLinuxConfig(config *compute.LinuxConfiguration) []interface{} {
-	result := make(map[string]interface{})
-	result["disable_password_authentication"] = *config.DisablePasswordAuthentication
-
+// waitForVirtualMachineScaleSetDeletion waits for a scale set to be deleted.
+// If it is still present once the wait gives up, which happens when some of
+// its instances fail to delete, the error includes the state Azure reports
+// for it rather than only the timeout.
+func waitForVirtualMachineScaleSetDeletion(stateConf *resource.StateChangeConf, client *ArmClient, resourceGroupName string, scaleSetName string) error {
+	_, waitErr := virtualMachineScaleSetWaitForState(stateConf, time.Now, time.Sleep)
+	if waitErr == nil {
+		return nil
+	}
+
+	resp, err := client.vmScaleSetClient.Get(resourceGroupName, scaleSetName)
+	if err != nil || resp.Properties == nil || resp.Properties.ProvisioningState == nil {
+		return fmt.Errorf("Error waiting for Virtual Machine Scale Set (%s) to be deleted: %s", scaleSetName, waitErr)
+	}
+
+	return fmt.Errorf("Error waiting for Virtual Machine Scale Set (%s) to be deleted: %s. The scale set still exists with provisioning state %q; some of its instances may have failed to delete", scaleSetName, waitErr, *resp.Properties.ProvisioningState)
+}
+
+// virtualMachineScaleSetWaitForState waits for conf to reach a target state as
+// conf.WaitForState does, backing off between refreshes from 100ms up to 10s
+// but never less than conf.MinTimeout. The clock and sleep are passed in so
+// that the polling cadence can be tested without waiting on it.
+func virtualMachineScaleSetWaitForState(conf *resource.StateChangeConf, now func() time.Time, sleep func(time.Duration)) (interface{}, error) {
+	start := now()
+
+	for tries := 0; ; tries++ {
+		wait := time.Duration(math.Pow(2, float64(tries))) * 100 * time.Millisecond
+		if wait < conf.MinTimeout {
+			wait = conf.MinTimeout
+		} else if wait > 10*time.Second {
+			wait = 10 * time.Second
+		}
+
+		log.Printf("[TRACE] Waiting %s before next try", wait)
+		sleep(wait)
+
+		result, state, err := conf.Refresh()
+		if err != nil {
+			return nil, err
+		}
+
+		pending := false
+		for _, target := range conf.Target {
+			if state == target {
+				return result, nil
+			}
+		}
+		for _, allowed := range conf.Pending {
+			if state == allowed {
+				pending = true
+			}
+		}
+
+		if !pending {
+			return nil, &resource.UnexpectedStateError{
+				State:         state,
+				ExpectedState: conf.Target,
+			}
+		}
+
+		if now().Sub(start) >= conf.Timeout {
+			return nil, &resource.TimeoutError{
+				ExpectedState: conf.Target,
+			}
+		}
+	}
+}
+
+func virtualMachineScaleSetDeleteStateChangeConf(d *schema.ResourceData, client *ArmClient, resourceGroupName string, scaleSetName string) *resource.StateChangeConf {
+	timeout := virtualMachineScaleSetOperationTimeout(client)
+	return &resource.StateChangeConf{
+		Pending:    []string{"Deleting", "Succeeded", "Updating"},
+		Target:     []string{"NotFound"},
+		Refresh:    virtualMachineScaleSetStateRefreshFunc(client, resourceGroupName, scaleSetName, timeout, time.Now),
+		Timeout:    timeout,
+		MinTimeout: resourceArmVirtualMachineScaleSetPollingInterval(d, client),
+	}
+}
+
+// resourceArmVirtualMachineScaleSetPollingInterval returns the minimum time to
+// wait between checks on a long-running scale set operation. The value has
+// already been validated, so an unparseable value falls back to the provider
+// default.
+func resourceArmVirtualMachineScaleSetPollingInterval(d *schema.ResourceData, client *ArmClient) time.Duration {
+	if v, ok := d.GetOk("polling_interval"); ok {
+		if interval, err := time.ParseDuration(v.(string)); err == nil {
+			return interval
+		}
+	}
+
+	if client.pollingInterval > 0 {
+		return client.pollingInterval
+	}
+	return defaultAzureRMPollingInterval
+}
+
+// virtualMachineScaleSetOperationTimeout returns how long to wait on a
+// long-running scale set operation, which is the provider default when set.
+func virtualMachineScaleSetOperationTimeout(client *ArmClient) time.Duration {
+	if client.operationTimeout > 0 {
+		return client.operationTimeout
+	}
+	return defaultAzureRMOperationTimeout
+}
+
+func flattenAzureRmVirtualMachineScaleSetOsProfileLinuxConfig(config *compute.LinuxConfiguration) []interface{} {
+	result := make(map[string]interface{})
+	result["disable_password_authentication"] = *config.DisablePasswordAuthentication
+
 	if config.SSH != nil && len(*config.SSH.PublicKeys) > 0 {
 		ssh_keys := make([]map[string]interface{}, len(*config.SSH.PublicKeys))
 		for _, i := range *config.SSH.PublicKeys {
@@ -581,7 +1171,7 @@ func flattenAzureRmVirtualMachineScaleSetOsProfileSecrets(secrets *[]compute.Vau
 		}
 
 		if secret.VaultCertificates != nil {
-			certs := make([]map[string]interface{}, 0, len(*secret.VaultCertificates))
+			certs := make([]interface{}, 0, len(*secret.VaultCertificates))
 			for _, cert := range *secret.VaultCertificates {
 				vaultCert := make(map[string]interface{})
 				vaultCert["certificate_url"] = *cert.CertificateURL
@@ -593,7 +1183,7 @@ func flattenAzureRmVirtualMachineScaleSetOsProfileSecrets(secrets *[]compute.Vau
 				certs = append(certs, vaultCert)
 			}
 
-			s["vault_certificates"] = certs
+			s["vault_certificates"] = schema.NewSet(resourceArmVirtualMachineScaleSetVaultCertificatesHash, certs)
 		}
 
 		result = append(result, s)
@@ -610,23 +1200,38 @@ func flattenAzureRmVirtualMachineScaleSetNetworkProfile(profile *compute.Virtual
 			"primary": *netConfig.Properties.Primary,
 		}
 
+		if netConfig.ID != nil {
+			s["id"] = *netConfig.ID
+		}
+
 		if netConfig.Properties.IPConfigurations != nil {
 			ipConfigs := make([]map[string]interface{}, 0, len(*netConfig.Properties.IPConfigurations))
 			for _, ipConfig := range *netConfig.Properties.IPConfigurations {
 				config := make(map[string]interface{})
 				config["name"] = *ipConfig.Name
 
+				if ipConfig.Properties == nil {
+					ipConfigs = append(ipConfigs, config)
+					continue
+				}
+
 				if ipConfig.Properties.Subnet != nil {
 					config["subnet_id"] = *ipConfig.Properties.Subnet.ID
 				}
 
 				if ipConfig.Properties.LoadBalancerBackendAddressPools != nil {
-					addressPools := make([]string, 0, len(*ipConfig.Properties.LoadBalancerBackendAddressPools))
-					for _, pool := range *ipConfig.Properties.LoadBalancerBackendAddressPools {
-						addressPools = append(addressPools, *pool.ID)
-					}
-					config["load_balancer_backend_address_pool_ids"] = addressPools
+					config["load_balancer_backend_address_pool_ids"] = flattenAzureRmVirtualMachineScaleSetSubResources(ipConfig.Properties.LoadBalancerBackendAddressPools)
+				}
+
+				if ipConfig.Properties.ApplicationGatewayBackendAddressPools != nil {
+					config["application_gateway_backend_address_pool_ids"] = flattenAzureRmVirtualMachineScaleSetSubResources(ipConfig.Properties.ApplicationGatewayBackendAddressPools)
+				}
+
+				if ipConfig.Properties.LoadBalancerInboundNatPools != nil {
+					config["load_balancer_inbound_nat_pool_ids"] = flattenAzureRmVirtualMachineScaleSetSubResources(ipConfig.Properties.LoadBalancerInboundNatPools)
 				}
+
+				ipConfigs = append(ipConfigs, config)
 			}
 
 			s["ip_configuration"] = ipConfigs
@@ -638,12 +1243,35 @@ func flattenAzureRmVirtualMachineScaleSetNetworkProfile(profile *compute.Virtual
 	return result
 }
 
-func flattenAzureRMVirtualMachineScaleSetOsProfile(profile *compute.VirtualMachineScaleSetOSProfile) []interface{} {
+func flattenAzureRmVirtualMachineScaleSetSubResources(resources *[]compute.SubResource) *schema.Set {
+	ids := make([]interface{}, 0, len(*resources))
+	for _, subResource := range *resources {
+		if subResource.ID != nil {
+			ids = append(ids, *subResource.ID)
+		}
+	}
+
+	return schema.NewSet(schema.HashString, ids)
+}
+
+// flattenAzureRMVirtualMachineScaleSetOsProfile flattens the OS profile of a
+// scale set. Azure never returns the admin password or custom data, so the
+// values already in state are kept rather than being cleared, which would
+// otherwise show as a change to the OS profile and recreate the scale set.
+func flattenAzureRMVirtualMachineScaleSetOsProfile(d *schema.ResourceData, profile *compute.VirtualMachineScaleSetOSProfile) []interface{} {
 	result := make(map[string]interface{})
 
 	result["computer_name_prefix"] = *profile.ComputerNamePrefix
 	result["admin_username"] = *profile.AdminUsername
 
+	if v, ok := d.GetOk("os_profile"); ok {
+		if existing := v.(*schema.Set).List(); len(existing) == 1 {
+			config := existing[0].(map[string]interface{})
+			result["admin_password"] = config["admin_password"]
+			result["custom_data"] = config["custom_data"]
+		}
+	}
+
 	if profile.CustomData != nil {
 		result["custom_data"] = *profile.CustomData
 	}
@@ -670,6 +1298,27 @@ func flattenAzureRmVirtualMachineScaleSetStorageProfileOSDisk(profile *compute.V
 	return []interface{}{result}
 }
 
+// flattenAzureRmVirtualMachineScaleSetTags converts the tags returned by Azure
+// into a map for state. As tag keys are case-insensitive, a key which only
+// differs in case from one in existing keeps the casing from existing, so it
+// does not show as a change.
+func flattenAzureRmVirtualMachineScaleSetTags(tagsMap *map[string]*string, existing map[string]interface{}) map[string]interface{} {
+	existingKeys := make(map[string]string, len(existing))
+	for k := range existing {
+		existingKeys[strings.ToLower(k)] = k
+	}
+
+	output := make(map[string]interface{}, len(*tagsMap))
+	for i, v := range *tagsMap {
+		if k, ok := existingKeys[strings.ToLower(i)]; ok {
+			i = k
+		}
+		output[i] = *v
+	}
+
+	return output
+}
+
 func flattenAzureRmVirtualMachineScaleSetStorageProfileImageReference(profile *compute.ImageReference) []interface{} {
 	result := make(map[string]interface{})
 	result["publisher"] = *profile.Publisher
@@ -680,29 +1329,235 @@ func flattenAzureRmVirtualMachineScaleSetStorageProfileImageReference(profile *c
 	return []interface{}{result}
 }
 
+// flattenAzureRmVirtualMachineScaleSetExtensionProfile flattens the extensions
+// returned by Azure. Protected settings are never returned by the API, so the
+// values already held in state are carried over for extensions of the same name,
+// as are settings which are equivalent JSON to what Azure returned.
+func flattenAzureRmVirtualMachineScaleSetExtensionProfile(d *schema.ResourceData, profile *compute.VirtualMachineScaleSetExtensionProfile) ([]map[string]interface{}, error) {
+	if profile.Extensions == nil {
+		return nil, nil
+	}
+
+	existingSettings := make(map[string]string)
+	protectedSettings := make(map[string]string)
+	if v, ok := d.GetOk("extension"); ok {
+		for _, e := range v.(*schema.Set).List() {
+			extension := e.(map[string]interface{})
+			existingSettings[extension["name"].(string)] = extension["settings"].(string)
+			protectedSettings[extension["name"].(string)] = extension["protected_settings"].(string)
+		}
+	}
+
+	result := make([]map[string]interface{}, 0, len(*profile.Extensions))
+	for _, extension := range *profile.Extensions {
+		if isArmVirtualMachineScaleSetApplicationHealthExtension(extension) {
+			continue
+		}
+
+		e := make(map[string]interface{})
+		e["name"] = *extension.Name
+		properties := extension.Properties
+		if properties != nil {
+			e["publisher"] = *properties.Publisher
+			e["type"] = *properties.Type
+			e["type_handler_version"] = *properties.TypeHandlerVersion
+			if properties.AutoUpgradeMinorVersion != nil {
+				e["auto_upgrade_minor_version"] = *properties.AutoUpgradeMinorVersion
+			}
+
+			if properties.Settings != nil {
+				settings, err := json.Marshal(*properties.Settings)
+				if err != nil {
+					return nil, err
+				}
+				e["settings"] = string(settings)
+
+				if existing, ok := existingSettings[*extension.Name]; ok && normalizeJson(existing) == string(settings) {
+					e["settings"] = existing
+				}
+			}
+		}
+
+		// Azure never returns protected settings, so the ones last applied are
+		// kept from state
+		if v, ok := protectedSettings[*extension.Name]; ok {
+			e["protected_settings"] = v
+			e["protected_settings_hash"] = virtualMachineScaleSetProtectedSettingsHash(v)
+		}
+
+		result = append(result, e)
+	}
+
+	return result, nil
+}
+
+// virtualMachineScaleSetProtectedSettingsHash returns a SHA-256 hash of the
+// normalized protected settings of an extension, or an empty string if there
+// are none. It is exported as protected_settings_hash, so that a change to the
+// protected settings can be seen without revealing them.
+func virtualMachineScaleSetProtectedSettingsHash(protectedSettings string) string {
+	normalized := normalizeJson(protectedSettings)
+	if normalized == "" {
+		return ""
+	}
+
+	hash := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(hash[:])
+}
+
+func flattenAzureRmVirtualMachineScaleSetApplicationHealthExtension(profile *compute.VirtualMachineScaleSetExtensionProfile) []interface{} {
+	if profile.Extensions == nil {
+		return nil
+	}
+
+	for _, extension := range *profile.Extensions {
+		if !isArmVirtualMachineScaleSetApplicationHealthExtension(extension) {
+			continue
+		}
+
+		result := make(map[string]interface{})
+		if extension.Properties.Settings != nil {
+			settings := *extension.Properties.Settings
+			if v, ok := settings["protocol"].(string); ok {
+				result["protocol"] = v
+			}
+			if v, ok := settings["port"].(float64); ok {
+				result["port"] = int(v)
+			}
+			if v, ok := settings["requestPath"].(string); ok {
+				result["request_path"] = v
+			}
+		}
+
+		return []interface{}{result}
+	}
+
+	return nil
+}
+
+// isArmVirtualMachineScaleSetApplicationHealthExtension returns whether an
+// extension is the one managed through application_health_extension, rather
+// than through the extension block.
+func isArmVirtualMachineScaleSetApplicationHealthExtension(extension compute.VirtualMachineScaleSetExtension) bool {
+	if extension.Name == nil || *extension.Name != virtualMachineScaleSetApplicationHealthExtensionName {
+		return false
+	}
+
+	properties := extension.Properties
+	return properties != nil && properties.Publisher != nil && *properties.Publisher == virtualMachineScaleSetApplicationHealthExtensionPublisher
+}
+
+// flattenAzureRmVirtualMachineScaleSetInstanceView counts the instances in a
+// scale set by their provisioning state, using the summary of status codes
+// such as "ProvisioningState/succeeded" in the instance view.
+func flattenAzureRmVirtualMachineScaleSetInstanceView(view compute.VirtualMachineScaleSetInstanceView) (int, map[string]interface{}) {
+	instances := 0
+	provisioningStates := make(map[string]interface{})
+
+	if view.VirtualMachine == nil || view.VirtualMachine.StatusesSummary == nil {
+		return instances, provisioningStates
+	}
+
+	for _, summary := range *view.VirtualMachine.StatusesSummary {
+		if summary.Code == nil || summary.Count == nil {
+			continue
+		}
+
+		if !strings.HasPrefix(*summary.Code, "ProvisioningState/") {
+			continue
+		}
+
+		state := strings.TrimPrefix(*summary.Code, "ProvisioningState/")
+		instances += int(*summary.Count)
+		provisioningStates[state] = strconv.Itoa(int(*summary.Count))
+	}
+
+	return instances, provisioningStates
+}
+
 func flattenAzureRmVirtualMachineScaleSetSku(sku *compute.Sku) []interface{} {
 	result := make(map[string]interface{})
 	result["name"] = *sku.Name
-	result["capacity"] = *sku.Capacity
 
-	if *sku.Tier != "" {
+	// Azure may omit the capacity of a scale set which has been scaled to zero
+	result["capacity"] = int64(0)
+	if sku.Capacity != nil {
+		result["capacity"] = *sku.Capacity
+	}
+
+	if sku.Tier != nil && *sku.Tier != "" {
 		result["tier"] = *sku.Tier
 	}
 
 	return []interface{}{result}
 }
 
-func virtualMachineScaleSetStateRefreshFunc(client *ArmClient, resourceGroupName string, scaleSetName string) resource.StateRefreshFunc {
+// virtualMachineScaleSetFailedProvisioningStates are the provisioning states
+// from which a scale set will not recover on its own, so there is no point
+// waiting for the target state once one of them is reported.
+var virtualMachineScaleSetFailedProvisioningStates = []string{"Failed", "Canceled"}
+
+// virtualMachineScaleSetStateRefreshFunc refreshes the provisioning state of a
+// scale set. Once more than half of timeout has passed since it was created,
+// according to now, it logs a warning with the elapsed time and the current
+// state, so that slow operations can be followed in the logs.
+func virtualMachineScaleSetStateRefreshFunc(client *ArmClient, resourceGroupName string, scaleSetName string, timeout time.Duration, now func() time.Time) resource.StateRefreshFunc {
+	start := now()
+	warned := false
+
 	return func() (interface{}, string, error) {
 		res, err := client.vmScaleSetClient.Get(resourceGroupName, scaleSetName)
+		if res.Response.Response != nil && res.StatusCode == http.StatusNotFound {
+			return res, "NotFound", nil
+		}
 		if err != nil {
 			return nil, "", fmt.Errorf("Error issuing read request in virtualMachineScaleSetStateRefreshFunc to Azure ARM for Virtual Machine Scale Set '%s' (RG: '%s'): %s", scaleSetName, resourceGroupName, err)
 		}
 
-		return res, *res.Properties.ProvisioningState, nil
+		if res.Properties == nil || res.Properties.ProvisioningState == nil {
+			return nil, "", fmt.Errorf("Error in virtualMachineScaleSetStateRefreshFunc: Azure ARM returned no provisioning state for Virtual Machine Scale Set '%s' (RG: '%s')", scaleSetName, resourceGroupName)
+		}
+
+		state := *res.Properties.ProvisioningState
+		for _, failed := range virtualMachineScaleSetFailedProvisioningStates {
+			if strings.EqualFold(state, failed) {
+				return res, state, fmt.Errorf("Virtual Machine Scale Set '%s' (RG: '%s') reached provisioning state %q%s", scaleSetName, resourceGroupName, state, virtualMachineScaleSetFailureDetail(client, resourceGroupName, scaleSetName))
+			}
+		}
+
+		if elapsed := now().Sub(start); !warned && elapsed > timeout/2 {
+			log.Printf("[WARN] Virtual Machine Scale Set '%s' (RG: '%s') is still in provisioning state %q after %s, more than half of the %s timeout", scaleSetName, resourceGroupName, state, elapsed, timeout)
+			warned = true
+		}
+
+		return res, state, nil
 	}
 }
 
+// virtualMachineScaleSetFailureDetail returns the error messages reported in
+// the instance view of a scale set, for inclusion in an error message. It is
+// best effort: if the instance view cannot be read an empty string is returned.
+func virtualMachineScaleSetFailureDetail(client *ArmClient, resourceGroupName string, scaleSetName string) string {
+	view, err := client.vmScaleSetClient.GetInstanceView(resourceGroupName, scaleSetName)
+	if err != nil || view.Statuses == nil {
+		return ""
+	}
+
+	messages := make([]string, 0)
+	for _, status := range *view.Statuses {
+		if status.Level != compute.Error || status.Message == nil {
+			continue
+		}
+		messages = append(messages, *status.Message)
+	}
+
+	if len(messages) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(": %s", strings.Join(messages, "; "))
+}
+
 func resourceArmVirtualMachineScaleSetStorageProfileImageReferenceHash(v interface{}) int {
 	var buf bytes.Buffer
 	m := v.(map[string]interface{})
@@ -746,6 +1601,50 @@ func resourceArmVirtualMachineScaleSetNetworkConfigurationHash(v interface{}) in
 	m := v.(map[string]interface{})
 	buf.WriteString(fmt.Sprintf("%s-", m["name"].(string)))
 	buf.WriteString(fmt.Sprintf("%t-", m["primary"].(bool)))
+
+	if v, ok := m["ip_configuration"]; ok {
+		for _, ipConfig := range v.([]interface{}) {
+			config := ipConfig.(map[string]interface{})
+			buf.WriteString(fmt.Sprintf("%s-", config["name"].(string)))
+			if subnetId, ok := config["subnet_id"]; ok {
+				buf.WriteString(fmt.Sprintf("%s-", subnetId.(string)))
+			}
+
+			for _, key := range []string{"load_balancer_backend_address_pool_ids", "application_gateway_backend_address_pool_ids", "load_balancer_inbound_nat_pool_ids"} {
+				if ids, ok := config[key]; ok && ids != nil {
+					sorted := make([]string, 0)
+					for _, id := range ids.(*schema.Set).List() {
+						sorted = append(sorted, id.(string))
+					}
+					sort.Strings(sorted)
+					buf.WriteString(fmt.Sprintf("%s-", strings.Join(sorted, ",")))
+				}
+			}
+		}
+	}
+
+	return hashcode.String(buf.String())
+}
+
+func resourceArmVirtualMachineScaleSetExtensionHash(v interface{}) int {
+	var buf bytes.Buffer
+	m := v.(map[string]interface{})
+	buf.WriteString(fmt.Sprintf("%s-", m["name"].(string)))
+	buf.WriteString(fmt.Sprintf("%s-", m["publisher"].(string)))
+	buf.WriteString(fmt.Sprintf("%s-", m["type"].(string)))
+	buf.WriteString(fmt.Sprintf("%s-", m["type_handler_version"].(string)))
+	if m["auto_upgrade_minor_version"] != nil {
+		buf.WriteString(fmt.Sprintf("%t-", m["auto_upgrade_minor_version"].(bool)))
+	}
+	if m["settings"] != nil {
+		buf.WriteString(fmt.Sprintf("%s-", normalizeJson(m["settings"].(string))))
+	}
+	// Azure never returns the protected settings, so they are compared as last
+	// applied, which is kept in state, and changing them re-applies the extension
+	if m["protected_settings"] != nil {
+		buf.WriteString(fmt.Sprintf("%s-", normalizeJson(m["protected_settings"])))
+	}
+
 	return hashcode.String(buf.String())
 }
 
@@ -755,8 +1654,31 @@ func resourceArmVirtualMachineScaleSetsOsProfileHash(v interface{}) int {
 	buf.WriteString(fmt.Sprintf("%s-", m["computer_name_prefix"].(string)))
 	buf.WriteString(fmt.Sprintf("%s-", m["admin_username"].(string)))
 	if m["custom_data"] != nil {
-		buf.WriteString(fmt.Sprintf("%s-", m["custom_data"].(string)))
+		buf.WriteString(fmt.Sprintf("%s-", normalizeArmVirtualMachineScaleSetCustomData(m["custom_data"])))
+	}
+	return hashcode.String(buf.String())
+}
+
+func resourceArmVirtualMachineScaleSetApplicationHealthExtensionHash(v interface{}) int {
+	var buf bytes.Buffer
+	m := v.(map[string]interface{})
+	buf.WriteString(fmt.Sprintf("%s-", m["protocol"].(string)))
+	buf.WriteString(fmt.Sprintf("%d-", m["port"].(int)))
+	if m["request_path"] != nil {
+		buf.WriteString(fmt.Sprintf("%s-", m["request_path"].(string)))
+	}
+
+	return hashcode.String(buf.String())
+}
+
+func resourceArmVirtualMachineScaleSetVaultCertificatesHash(v interface{}) int {
+	var buf bytes.Buffer
+	m := v.(map[string]interface{})
+	buf.WriteString(fmt.Sprintf("%s-", m["certificate_url"].(string)))
+	if m["certificate_store"] != nil {
+		buf.WriteString(fmt.Sprintf("%s-", m["certificate_store"].(string)))
 	}
+
 	return hashcode.String(buf.String())
 }
 
@@ -782,6 +1704,9 @@ func resourceArmVirtualMachineScaleSetOsProfileLWindowsConfigHash(v interface{})
 
 func expandVirtualMachineScaleSetSku(d *schema.ResourceData) (*compute.Sku, error) {
 	skuConfig := d.Get("sku").(*schema.Set).List()
+	if len(skuConfig) != 1 {
+		return nil, fmt.Errorf("Exactly one sku block must be specified for a Virtual Machine Scale Set, got %d", len(skuConfig))
+	}
 
 	config := skuConfig[0].(map[string]interface{})
 
@@ -789,6 +1714,11 @@ func expandVirtualMachineScaleSetSku(d *schema.ResourceData) (*compute.Sku, erro
 	tier := config["tier"].(string)
 	capacity := int64(config["capacity"].(int))
 
+	// Scale sets are deployed as a single placement group, which Azure caps at 100 instances
+	if capacity > virtualMachineScaleSetSinglePlacementGroupMaxCapacity {
+		return nil, fmt.Errorf("A Virtual Machine Scale Set in a single placement group can have at most %d instances, got a capacity of %d", virtualMachineScaleSetSinglePlacementGroupMaxCapacity, capacity)
+	}
+
 	sku := &compute.Sku{
 		Name:     &name,
 		Capacity: &capacity,
@@ -801,21 +1731,70 @@ func expandVirtualMachineScaleSetSku(d *schema.ResourceData) (*compute.Sku, erro
 	return sku, nil
 }
 
-func expandAzureRmVirtualMachineScaleSetNetworkProfile(d *schema.ResourceData) *compute.VirtualMachineScaleSetNetworkProfile {
+// expandAzureRmVirtualMachineScaleSetCapacityUpdate replaces the sku of the
+// existing scale set with the configured one, keeping everything else as it is.
+// Azure never returns the protected settings of the extensions, so the
+// extension profile is expanded from config rather than sent back without them.
+func expandAzureRmVirtualMachineScaleSetCapacityUpdate(d *schema.ResourceData, existing *compute.VirtualMachineScaleSet) (*compute.VirtualMachineScaleSet, error) {
+	sku, err := expandVirtualMachineScaleSetSku(d)
+	if err != nil {
+		return nil, err
+	}
+
+	extensionProfile, err := expandAzureRMVirtualMachineScaleSetExtensions(d)
+	if err != nil {
+		return nil, err
+	}
+
+	existing.Sku = sku
+	existing.Properties.VirtualMachineProfile.ExtensionProfile = extensionProfile
+	return existing, nil
+}
+
+// expandAzureRmVirtualMachineScaleSetExtensionsUpdate replaces the extension
+// profile of the existing scale set with the configured extensions, keeping
+// everything else as it is.
+func expandAzureRmVirtualMachineScaleSetExtensionsUpdate(d *schema.ResourceData, existing *compute.VirtualMachineScaleSet) (*compute.VirtualMachineScaleSet, error) {
+	extensionProfile, err := expandAzureRMVirtualMachineScaleSetExtensions(d)
+	if err != nil {
+		return nil, err
+	}
+
+	existing.Properties.VirtualMachineProfile.ExtensionProfile = extensionProfile
+	return existing, nil
+}
+
+func expandAzureRmVirtualMachineScaleSetNetworkProfile(d *schema.ResourceData) (*compute.VirtualMachineScaleSetNetworkProfile, error) {
 	scaleSetNetworkProfileConfigs := d.Get("network_profile").(*schema.Set).List()
 	networkProfileConfig := make([]compute.VirtualMachineScaleSetNetworkConfiguration, 0, len(scaleSetNetworkProfileConfigs))
 
+	primaryCount := 0
+	profileNames := make(map[string]bool, len(scaleSetNetworkProfileConfigs))
 	for _, npProfileConfig := range scaleSetNetworkProfileConfigs {
 		config := npProfileConfig.(map[string]interface{})
 
 		name := config["name"].(string)
+		if profileNames[name] {
+			return nil, fmt.Errorf("network_profile names must be unique, found more than one named %q", name)
+		}
+		profileNames[name] = true
+
 		primary := config["primary"].(bool)
+		if primary {
+			primaryCount++
+		}
 
 		ipConfigurationConfigs := config["ip_configuration"].([]interface{})
 		ipConfigurations := make([]compute.VirtualMachineScaleSetIPConfiguration, 0, len(ipConfigurationConfigs))
+		ipConfigurationNames := make(map[string]bool, len(ipConfigurationConfigs))
 		for _, ipConfigConfig := range ipConfigurationConfigs {
 			ipconfig := ipConfigConfig.(map[string]interface{})
 			name := ipconfig["name"].(string)
+			if ipConfigurationNames[name] {
+				return nil, fmt.Errorf("ip_configuration names must be unique within network_profile %q, found more than one named %q", config["name"], name)
+			}
+			ipConfigurationNames[name] = true
+
 			subnetId := ipconfig["subnet_id"].(string)
 
 			ipConfiguration := compute.VirtualMachineScaleSetIPConfiguration{
@@ -826,10 +1805,18 @@ func expandAzureRmVirtualMachineScaleSetNetworkProfile(d *schema.ResourceData) *
 					},
 				},
 			}
-			//TODO: Add the support for the load balancers when it drops
-			//if v := ipconfig["load_balancer_backend_address_pool_ids"]; v != nil {
-			//
-			//}
+
+			if v := ipconfig["load_balancer_backend_address_pool_ids"]; v != nil {
+				ipConfiguration.Properties.LoadBalancerBackendAddressPools = expandAzureRmVirtualMachineScaleSetSubResources(v.(*schema.Set))
+			}
+
+			if v := ipconfig["application_gateway_backend_address_pool_ids"]; v != nil {
+				ipConfiguration.Properties.ApplicationGatewayBackendAddressPools = expandAzureRmVirtualMachineScaleSetSubResources(v.(*schema.Set))
+			}
+
+			if v := ipconfig["load_balancer_inbound_nat_pool_ids"]; v != nil {
+				ipConfiguration.Properties.LoadBalancerInboundNatPools = expandAzureRmVirtualMachineScaleSetSubResources(v.(*schema.Set))
+			}
 
 			ipConfigurations = append(ipConfigurations, ipConfiguration)
 		}
@@ -845,9 +1832,25 @@ func expandAzureRmVirtualMachineScaleSetNetworkProfile(d *schema.ResourceData) *
 		networkProfileConfig = append(networkProfileConfig, nProfile)
 	}
 
+	if primaryCount != 1 {
+		return nil, fmt.Errorf("Exactly one network_profile must be marked as primary, found %d", primaryCount)
+	}
+
 	return &compute.VirtualMachineScaleSetNetworkProfile{
 		NetworkInterfaceConfigurations: &networkProfileConfig,
+	}, nil
+}
+
+func expandAzureRmVirtualMachineScaleSetSubResources(ids *schema.Set) *[]compute.SubResource {
+	resources := make([]compute.SubResource, 0, ids.Len())
+	for _, v := range ids.List() {
+		id := v.(string)
+		resources = append(resources, compute.SubResource{
+			ID: &id,
+		})
 	}
+
+	return &resources
 }
 
 func expandAzureRMVirtualMachineScaleSetsOsProfile(d *schema.ResourceData) (*compute.VirtualMachineScaleSetOSProfile, error) {
@@ -857,7 +1860,7 @@ func expandAzureRMVirtualMachineScaleSetsOsProfile(d *schema.ResourceData) (*com
 	namePrefix := osProfileConfig["computer_name_prefix"].(string)
 	username := osProfileConfig["admin_username"].(string)
 	password := osProfileConfig["admin_password"].(string)
-	customData := osProfileConfig["custom_data"].(string)
+	customData := normalizeArmVirtualMachineScaleSetCustomData(osProfileConfig["custom_data"])
 
 	osProfile := &compute.VirtualMachineScaleSetOSProfile{
 		ComputerNamePrefix: &namePrefix,
@@ -873,7 +1876,10 @@ func expandAzureRMVirtualMachineScaleSetsOsProfile(d *schema.ResourceData) (*com
 	}
 
 	if _, ok := d.GetOk("os_profile_secrets"); ok {
-		secrets := expandAzureRmVirtualMachineScaleSetOsProfileSecrets(d)
+		secrets, err := expandAzureRmVirtualMachineScaleSetOsProfileSecrets(d)
+		if err != nil {
+			return nil, err
+		}
 		if secrets != nil {
 			osProfile.Secrets = secrets
 		}
@@ -910,6 +1916,34 @@ func expandAzureRMVirtualMachineScaleSetsStorageProfileOsDisk(d *schema.Resource
 	osType := osDiskConfig["os_type"].(string)
 	createOption := osDiskConfig["create_option"].(string)
 
+	_, hasImageReference := d.GetOk("storage_profile_image_reference")
+	switch {
+	case strings.EqualFold(createOption, string(compute.FromImage)):
+		if hasImageReference && image != "" {
+			return nil, fmt.Errorf("Only one of storage_profile_image_reference or storage_profile_os_disk.image can be set when create_option is %q", createOption)
+		}
+		if !hasImageReference && image == "" {
+			return nil, fmt.Errorf("One of storage_profile_image_reference or storage_profile_os_disk.image must be set when create_option is %q", createOption)
+		}
+	case strings.EqualFold(createOption, string(compute.Empty)):
+		if hasImageReference || image != "" {
+			return nil, fmt.Errorf("Neither storage_profile_image_reference nor storage_profile_os_disk.image can be set when create_option is %q", createOption)
+		}
+	}
+
+	_, hasWindowsConfig := d.GetOk("os_profile_windows_config")
+	_, hasLinuxConfig := d.GetOk("os_profile_linux_config")
+	switch compute.OperatingSystemTypes(osType) {
+	case compute.Linux:
+		if hasWindowsConfig {
+			return nil, fmt.Errorf("os_profile_windows_config cannot be set when storage_profile_os_disk.os_type is %q", osType)
+		}
+	case compute.Windows:
+		if hasLinuxConfig {
+			return nil, fmt.Errorf("os_profile_linux_config cannot be set when storage_profile_os_disk.os_type is %q", osType)
+		}
+	}
+
 	var vhdContainers []string
 	containers := osDiskConfig["vhd_containers"].(*schema.Set).List()
 	for _, v := range containers {
@@ -1048,10 +2082,13 @@ func expandAzureRmVirtualMachineScaleSetOsProfileWindowsConfig(d *schema.Resourc
 	return config, nil
 }
 
-func expandAzureRmVirtualMachineScaleSetOsProfileSecrets(d *schema.ResourceData) *[]compute.VaultSecretGroup {
+func expandAzureRmVirtualMachineScaleSetOsProfileSecrets(d *schema.ResourceData) (*[]compute.VaultSecretGroup, error) {
 	secretsConfig := d.Get("os_profile_secrets").(*schema.Set).List()
 	secrets := make([]compute.VaultSecretGroup, 0, len(secretsConfig))
 
+	_, isWindows := d.GetOk("os_profile_windows_config")
+	_, isLinux := d.GetOk("os_profile_linux_config")
+
 	for _, secretConfig := range secretsConfig {
 		config := secretConfig.(map[string]interface{})
 		sourceVaultId := config["source_vault_id"].(string)
@@ -1072,8 +2109,15 @@ func expandAzureRmVirtualMachineScaleSetOsProfileSecrets(d *schema.ResourceData)
 				cert := compute.VaultCertificate{
 					CertificateURL: &certUrl,
 				}
-				if v := config["certificate_store"].(string); v != "" {
-					cert.CertificateStore = &v
+				certStore := config["certificate_store"].(string)
+				if isWindows && certStore == "" {
+					return nil, fmt.Errorf("certificate_store must be set for Vault Certificate %q on a Windows Virtual Machine Scale Set", certUrl)
+				}
+				if isLinux && certStore != "" {
+					return nil, fmt.Errorf("certificate_store cannot be set for Vault Certificate %q on a Linux Virtual Machine Scale Set", certUrl)
+				}
+				if certStore != "" {
+					cert.CertificateStore = &certStore
 				}
 
 				certs = append(certs, cert)
@@ -1084,5 +2128,382 @@ func expandAzureRmVirtualMachineScaleSetOsProfileSecrets(d *schema.ResourceData)
 		secrets = append(secrets, vaultSecretGroup)
 	}
 
-	return &secrets
+	return &secrets, nil
+}
+
+const (
+	virtualMachineScaleSetSinglePlacementGroupMaxCapacity = 100
+	virtualMachineScaleSetAdminPasswordMinLength          = 12
+	virtualMachineScaleSetAdminPasswordMaxLength          = 72
+
+	virtualMachineScaleSetCustomDataMaxLength = 65535
+
+	virtualMachineScaleSetApplicationHealthExtensionName      = "ApplicationHealthExtension"
+	virtualMachineScaleSetApplicationHealthExtensionPublisher = "Microsoft.ManagedServices"
+	virtualMachineScaleSetApplicationHealthExtensionVersion   = "1.0"
+)
+
+// validateArmVirtualMachineScaleSetCapacity caps the capacity at the size of a
+// single placement group, as that is how every scale set is deployed with this
+// API version, rather than at the 1000 instances Azure allows across several.
+func validateArmVirtualMachineScaleSetCapacity(v interface{}, k string) (ws []string, errors []error) {
+	value := v.(int)
+
+	if value < 0 || value > virtualMachineScaleSetSinglePlacementGroupMaxCapacity {
+		errors = append(errors, fmt.Errorf("%q must be between 0 and %d, as scale sets are deployed as a single placement group, got %d", k, virtualMachineScaleSetSinglePlacementGroupMaxCapacity, value))
+	}
+	return
+}
+
+// validateArmVirtualMachineScaleSetAdminPassword enforces the complexity rules
+// Azure applies to admin passwords: between 12 and 72 characters, containing
+// at least 3 of lowercase, uppercase, digit and special characters.
+func validateArmVirtualMachineScaleSetAdminPassword(v interface{}, k string) (ws []string, errors []error) {
+	value := v.(string)
+
+	if len(value) < virtualMachineScaleSetAdminPasswordMinLength || len(value) > virtualMachineScaleSetAdminPasswordMaxLength {
+		errors = append(errors, fmt.Errorf("%q must be between %d and %d characters long, got %d", k, virtualMachineScaleSetAdminPasswordMinLength, virtualMachineScaleSetAdminPasswordMaxLength, len(value)))
+	}
+
+	var lower, upper, digit, special bool
+	for _, c := range value {
+		switch {
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsDigit(c):
+			digit = true
+		default:
+			special = true
+		}
+	}
+
+	classes := 0
+	missing := make([]string, 0, 4)
+	for class, present := range map[string]bool{"lowercase": lower, "uppercase": upper, "digit": digit, "special": special} {
+		if present {
+			classes++
+		} else {
+			missing = append(missing, class)
+		}
+	}
+	sort.Strings(missing)
+
+	if classes < 3 {
+		errors = append(errors, fmt.Errorf("%q must contain at least 3 of lowercase, uppercase, digit and special characters, missing %s", k, strings.Join(missing, ", ")))
+	}
+	return
+}
+
+func validateArmVirtualMachineScaleSetApplicationHealthProtocol(v interface{}, k string) (ws []string, errors []error) {
+	value := v.(string)
+	protocols := map[string]bool{
+		"tcp":   true,
+		"http":  true,
+		"https": true,
+	}
+
+	if !protocols[value] {
+		errors = append(errors, fmt.Errorf("%q can only be tcp, http or https, got %q", k, value))
+	}
+	return
+}
+
+func validateArmVirtualMachineScaleSetPollingInterval(v interface{}, k string) (ws []string, errors []error) {
+	value := v.(string)
+
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%q must be a duration such as \"30s\" or \"1m\", got %q: %s", k, value, err))
+		return
+	}
+
+	if interval < time.Second {
+		errors = append(errors, fmt.Errorf("%q must be at least 1s, got %q", k, value))
+	}
+	return
+}
+
+// validateArmVirtualMachineScaleSetVhdContainer checks that a vhd_containers
+// entry is the URL of a blob container, such as
+// https://account.blob.core.windows.net/vhds
+func validateArmVirtualMachineScaleSetVhdContainer(v interface{}, k string) (ws []string, errors []error) {
+	value := v.(string)
+
+	containerURL, err := url.Parse(value)
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%q must be a blob container URL, got %q: %s", k, value, err))
+		return
+	}
+
+	switch containerURL.Scheme {
+	case "https":
+	case "http":
+		ws = append(ws, fmt.Sprintf("%q is an http blob container URL, so the disks are accessed without TLS; consider https instead: %q", k, value))
+	default:
+		errors = append(errors, fmt.Errorf("%q must be an http or https blob container URL, got %q", k, value))
+	}
+
+	hostParts := strings.SplitN(containerURL.Host, ".", 3)
+	if len(hostParts) != 3 || hostParts[0] == "" || hostParts[1] != "blob" || !strings.HasPrefix(hostParts[2], "core.") {
+		errors = append(errors, fmt.Errorf("%q must be hosted on a storage account blob endpoint such as <account>.blob.core.windows.net, got %q", k, value))
+	}
+
+	container := strings.Trim(containerURL.Path, "/")
+	if container == "" || strings.Contains(container, "/") {
+		errors = append(errors, fmt.Errorf("%q must be the URL of a single blob container such as https://<account>.blob.core.windows.net/<container>, got %q", k, value))
+	}
+	return
+}
+
+// decodeArmVirtualMachineScaleSetCustomData decodes base64 custom_data,
+// ignoring whitespace and missing padding.
+func decodeArmVirtualMachineScaleSetCustomData(value string) ([]byte, error) {
+	value = strings.Join(strings.Fields(value), "")
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+// normalizeArmVirtualMachineScaleSetCustomData re-encodes custom_data as
+// canonical base64, so that the same payload encoded differently does not
+// show as a change. Values that are not base64 are returned unchanged.
+func normalizeArmVirtualMachineScaleSetCustomData(v interface{}) string {
+	value, _ := v.(string)
+
+	decoded, err := decodeArmVirtualMachineScaleSetCustomData(value)
+	if err != nil {
+		return value
+	}
+	return base64.StdEncoding.EncodeToString(decoded)
+}
+
+// validateArmVirtualMachineScaleSetCustomData checks that custom_data is base64
+// encoded, which is how Azure expects it, and that the decoded payload fits
+// within the 64 KB Azure accepts.
+func validateArmVirtualMachineScaleSetCustomData(v interface{}, k string) (ws []string, errors []error) {
+	value := v.(string)
+
+	decoded, err := decodeArmVirtualMachineScaleSetCustomData(value)
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%q must be base64 encoded: %s", k, err))
+		return
+	}
+
+	if len(decoded) > virtualMachineScaleSetCustomDataMaxLength {
+		errors = append(errors, fmt.Errorf("%q can be at most %d bytes once decoded, got %d bytes", k, virtualMachineScaleSetCustomDataMaxLength, len(decoded)))
+	}
+	return
+}
+
+// validateArmVirtualMachineScaleSetTags applies the usual tag validation, and
+// also rejects keys which only differ in case, as Azure treats tag keys
+// case-insensitively and would apply them as a single tag.
+func validateArmVirtualMachineScaleSetTags(v interface{}, k string) (ws []string, errors []error) {
+	ws, errors = validateAzureRMTags(v, k)
+
+	keys := make(map[string]string)
+	for key := range v.(map[string]interface{}) {
+		if existing, ok := keys[strings.ToLower(key)]; ok {
+			errors = append(errors, fmt.Errorf("tag keys are case-insensitive: %q and %q refer to the same tag", existing, key))
+		}
+		keys[strings.ToLower(key)] = key
+	}
+	return
+}
+
+func validateArmVirtualMachineScaleSetCaching(v interface{}, k string) (ws []string, errors []error) {
+	value := v.(string)
+	cachingTypes := map[string]bool{
+		string(compute.None):      true,
+		string(compute.ReadOnly):  true,
+		string(compute.ReadWrite): true,
+	}
+
+	if !cachingTypes[value] {
+		errors = append(errors, fmt.Errorf("%q can only be %s, %s or %s, got %q", k, compute.None, compute.ReadOnly, compute.ReadWrite, value))
+	}
+	return
+}
+
+func validateArmVirtualMachineScaleSetOsType(v interface{}, k string) (ws []string, errors []error) {
+	value := v.(string)
+	osTypes := map[string]bool{
+		string(compute.Linux):   true,
+		string(compute.Windows): true,
+	}
+
+	if !osTypes[value] {
+		errors = append(errors, fmt.Errorf("%q can only be %s or %s, got %q", k, compute.Linux, compute.Windows, value))
+	}
+	return
+}
+
+func validateArmVirtualMachineScaleSetSkuTier(v interface{}, k string) (ws []string, errors []error) {
+	value := v.(string)
+	tiers := map[string]bool{
+		"Standard": true,
+		"Basic":    true,
+	}
+
+	if !tiers[value] {
+		errors = append(errors, fmt.Errorf("%q can only be Standard or Basic, got %q", k, value))
+	}
+	return
+}
+
+// validateArmVirtualMachineScaleSetUpgradePolicyMode accepts the upgrade modes
+// of the compute API version in use. Rolling upgrades came in a later API
+// version which the vendored SDK has no constant for, so Rolling is rejected
+// with an explanation rather than the generic error.
+func validateArmVirtualMachineScaleSetUpgradePolicyMode(v interface{}, k string) (ws []string, errors []error) {
+	value := v.(string)
+	modes := map[string]bool{
+		string(compute.Automatic): true,
+		string(compute.Manual):    true,
+	}
+
+	if value == "Rolling" {
+		errors = append(errors, fmt.Errorf("%q cannot be Rolling, as the %s compute API version used by this provider only supports %s or %s", k, compute.APIVersion, compute.Automatic, compute.Manual))
+		return
+	}
+
+	if !modes[value] {
+		errors = append(errors, fmt.Errorf("%q can only be %s or %s, got %q", k, compute.Automatic, compute.Manual, value))
+	}
+	return
+}
+
+func validateArmLoadBalancerInboundNatPoolId(v interface{}, k string) (ws []string, errors []error) {
+	value := v.(string)
+
+	id, err := parseAzureResourceID(value)
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%q must be a valid Load Balancer Inbound NAT Pool ID: %s", k, err))
+		return
+	}
+
+	if id.Provider != "Microsoft.Network" || id.Path["loadBalancers"] == "" || id.Path["inboundNatPools"] == "" {
+		errors = append(errors, fmt.Errorf("%q must be a valid Load Balancer Inbound NAT Pool ID, got %q", k, value))
+	}
+
+	return
+}
+
+func expandAzureRMVirtualMachineScaleSetExtensions(d *schema.ResourceData) (*compute.VirtualMachineScaleSetExtensionProfile, error) {
+	extensions := d.Get("extension").(*schema.Set).List()
+	resources := make([]compute.VirtualMachineScaleSetExtension, 0, len(extensions))
+	for _, e := range extensions {
+		config := e.(map[string]interface{})
+		name := config["name"].(string)
+		publisher := config["publisher"].(string)
+		t := config["type"].(string)
+		version := config["type_handler_version"].(string)
+
+		extension := compute.VirtualMachineScaleSetExtension{
+			Name: &name,
+			Properties: &compute.VirtualMachineScaleSetExtensionProperties{
+				Publisher:          &publisher,
+				Type:               &t,
+				TypeHandlerVersion: &version,
+			},
+		}
+
+		if u := config["auto_upgrade_minor_version"]; u != nil {
+			upgrade := u.(bool)
+			extension.Properties.AutoUpgradeMinorVersion = &upgrade
+		}
+
+		if s := config["settings"].(string); s != "" {
+			settings, err := expandArmVirtualMachineScaleSetExtensionSettings(s)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse settings for extension %s: %s", name, err)
+			}
+			extension.Properties.Settings = &settings
+		}
+
+		if s := config["protected_settings"].(string); s != "" {
+			protectedSettings, err := expandArmVirtualMachineScaleSetExtensionSettings(s)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse protected_settings for extension %s: %s", name, err)
+			}
+			extension.Properties.ProtectedSettings = &protectedSettings
+		}
+
+		resources = append(resources, extension)
+	}
+
+	healthExtensions := d.Get("application_health_extension").(*schema.Set).List()
+	if len(healthExtensions) > 0 {
+		_, isWindows := d.GetOk("os_profile_windows_config")
+		extension, err := expandAzureRmVirtualMachineScaleSetApplicationHealthExtension(healthExtensions[0].(map[string]interface{}), isWindows)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, *extension)
+	}
+
+	return &compute.VirtualMachineScaleSetExtensionProfile{
+		Extensions: &resources,
+	}, nil
+}
+
+func expandAzureRmVirtualMachineScaleSetApplicationHealthExtension(config map[string]interface{}, isWindows bool) (*compute.VirtualMachineScaleSetExtension, error) {
+	protocol := config["protocol"].(string)
+	port := config["port"].(int)
+	requestPath := config["request_path"].(string)
+
+	if protocol == "tcp" && requestPath != "" {
+		return nil, fmt.Errorf("request_path cannot be set for an application_health_extension using the tcp protocol")
+	}
+	if protocol != "tcp" && requestPath == "" {
+		return nil, fmt.Errorf("request_path must be set for an application_health_extension using the %s protocol", protocol)
+	}
+
+	settings := map[string]interface{}{
+		"protocol": protocol,
+		"port":     port,
+	}
+	if requestPath != "" {
+		settings["requestPath"] = requestPath
+	}
+
+	name := virtualMachineScaleSetApplicationHealthExtensionName
+	publisher := virtualMachineScaleSetApplicationHealthExtensionPublisher
+	t := "ApplicationHealthLinux"
+	if isWindows {
+		t = "ApplicationHealthWindows"
+	}
+	version := virtualMachineScaleSetApplicationHealthExtensionVersion
+	autoUpgrade := true
+
+	return &compute.VirtualMachineScaleSetExtension{
+		Name: &name,
+		Properties: &compute.VirtualMachineScaleSetExtensionProperties{
+			Publisher:               &publisher,
+			Type:                    &t,
+			TypeHandlerVersion:      &version,
+			AutoUpgradeMinorVersion: &autoUpgrade,
+			Settings:                &settings,
+		},
+	}, nil
+}
+
+func expandArmVirtualMachineScaleSetExtensionSettings(jsonString string) (map[string]interface{}, error) {
+	var result map[string]interface{}
+
+	err := json.Unmarshal([]byte(jsonString), &result)
+
+	return result, err
+}
+
+func validateJsonString(v interface{}, k string) (ws []string, errors []error) {
+	if v.(string) == "" {
+		return
+	}
+
+	var j map[string]interface{}
+	if err := json.Unmarshal([]byte(v.(string)), &j); err != nil {
+		errors = append(errors, fmt.Errorf("%q contains an invalid JSON object: %s", k, err))
+	}
+	return
 }
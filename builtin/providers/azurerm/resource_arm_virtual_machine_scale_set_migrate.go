@@ -0,0 +1,100 @@
+package azurerm
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// legacyVirtualMachineScaleSetOsProfileFields are the os_profile attributes
+// which may have been written at the top level of v0 state.
+var legacyVirtualMachineScaleSetOsProfileFields = []string{
+	"computer_name_prefix",
+	"admin_username",
+	"admin_password",
+	"custom_data",
+}
+
+// sensitiveVirtualMachineScaleSetFields are the attributes which are left out
+// when the state is logged during a migration.
+var sensitiveVirtualMachineScaleSetFields = []string{
+	"admin_password",
+	"custom_data",
+	"protected_settings",
+}
+
+func resourceArmVirtualMachineScaleSetMigrateState(
+	v int, is *terraform.InstanceState, meta interface{}) (*terraform.InstanceState, error) {
+	switch v {
+	case 0:
+		log.Println("[INFO] Found AzureRM Virtual Machine Scale Set State v0; migrating to v1")
+		return migrateAzureRMVirtualMachineScaleSetStateV0toV1(is)
+	default:
+		return is, fmt.Errorf("Unexpected schema version: %d", v)
+	}
+}
+
+func migrateAzureRMVirtualMachineScaleSetStateV0toV1(is *terraform.InstanceState) (*terraform.InstanceState, error) {
+	if is.Empty() {
+		log.Println("[DEBUG] Empty InstanceState; nothing to migrate.")
+		return is, nil
+	}
+
+	log.Printf("[DEBUG] Attributes before migration: %#v", loggableVirtualMachineScaleSetAttributes(is.Attributes))
+
+	if count, ok := is.Attributes["os_profile.#"]; ok && count != "0" {
+		log.Println("[DEBUG] os_profile already present; nothing to migrate.")
+		return is, nil
+	}
+
+	osProfile := make(map[string]interface{})
+	for _, field := range legacyVirtualMachineScaleSetOsProfileFields {
+		if v, ok := is.Attributes[field]; ok {
+			osProfile[field] = v
+		}
+	}
+
+	if len(osProfile) == 0 {
+		log.Println("[DEBUG] No legacy os_profile fields found; nothing to migrate.")
+		return is, nil
+	}
+
+	for _, field := range []string{"computer_name_prefix", "admin_username", "admin_password"} {
+		if _, ok := osProfile[field]; !ok {
+			osProfile[field] = ""
+		}
+	}
+
+	hash := resourceArmVirtualMachineScaleSetsOsProfileHash(osProfile)
+	for field, v := range osProfile {
+		is.Attributes[fmt.Sprintf("os_profile.%d.%s", hash, field)] = v.(string)
+		delete(is.Attributes, field)
+	}
+	is.Attributes["os_profile.#"] = "1"
+
+	log.Printf("[DEBUG] Attributes after migration: %#v", loggableVirtualMachineScaleSetAttributes(is.Attributes))
+	return is, nil
+}
+
+// loggableVirtualMachineScaleSetAttributes returns a copy of the attributes
+// without the sensitive fields, at the top level or nested.
+func loggableVirtualMachineScaleSetAttributes(attributes map[string]string) map[string]string {
+	loggable := make(map[string]string, len(attributes))
+	for k, v := range attributes {
+		parts := strings.Split(k, ".")
+		sensitive := false
+		for _, field := range sensitiveVirtualMachineScaleSetFields {
+			if parts[len(parts)-1] == field {
+				sensitive = true
+			}
+		}
+
+		if !sensitive {
+			loggable[k] = v
+		}
+	}
+
+	return loggable
+}
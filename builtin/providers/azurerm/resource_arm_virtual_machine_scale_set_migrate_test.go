@@ -0,0 +1,123 @@
+package azurerm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAzureRMVirtualMachineScaleSetMigrateState(t *testing.T) {
+	cases := map[string]struct {
+		StateVersion int
+		Attributes   map[string]string
+		Expected     map[string]string
+		Meta         interface{}
+	}{
+		"v0_legacy_fields": {
+			StateVersion: 0,
+			Attributes: map[string]string{
+				"name":                 "acctvmss",
+				"computer_name_prefix": "testvm",
+				"admin_username":       "myadmin",
+				"admin_password":       "Passwword1234",
+			},
+			Expected: map[string]string{
+				"name":                                       "acctvmss",
+				"os_profile.#":                               "1",
+				"os_profile.2669702628.admin_password":       "Passwword1234",
+				"os_profile.2669702628.admin_username":       "myadmin",
+				"os_profile.2669702628.computer_name_prefix": "testvm",
+			},
+		},
+		"v0_os_profile_present": {
+			StateVersion: 0,
+			Attributes: map[string]string{
+				"name":                                       "acctvmss",
+				"os_profile.#":                               "1",
+				"os_profile.2669702628.admin_password":       "Passwword1234",
+				"os_profile.2669702628.admin_username":       "myadmin",
+				"os_profile.2669702628.computer_name_prefix": "testvm",
+			},
+			Expected: map[string]string{
+				"name":                                       "acctvmss",
+				"os_profile.#":                               "1",
+				"os_profile.2669702628.admin_password":       "Passwword1234",
+				"os_profile.2669702628.admin_username":       "myadmin",
+				"os_profile.2669702628.computer_name_prefix": "testvm",
+			},
+		},
+		"v0_no_os_profile": {
+			StateVersion: 0,
+			Attributes: map[string]string{
+				"name": "acctvmss",
+			},
+			Expected: map[string]string{
+				"name": "acctvmss",
+			},
+		},
+	}
+
+	for tn, tc := range cases {
+		is := &terraform.InstanceState{
+			ID:         "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Compute/virtualMachineScaleSets/acctvmss",
+			Attributes: tc.Attributes,
+		}
+		is, err := resourceArmVirtualMachineScaleSetMigrateState(tc.StateVersion, is, tc.Meta)
+		if err != nil {
+			t.Fatalf("bad: %s, err: %#v", tn, err)
+		}
+
+		if !reflect.DeepEqual(is.Attributes, tc.Expected) {
+			t.Fatalf("bad: %s\n\n expected: %#v\n\n got: %#v", tn, tc.Expected, is.Attributes)
+		}
+	}
+}
+
+func TestAzureRMVirtualMachineScaleSetMigrateState_empty(t *testing.T) {
+	var is *terraform.InstanceState
+	var meta interface{}
+
+	// should handle nil
+	is, err := resourceArmVirtualMachineScaleSetMigrateState(0, is, meta)
+	if err != nil {
+		t.Fatalf("err: %#v", err)
+	}
+	if is != nil {
+		t.Fatalf("expected nil instancestate, got: %#v", is)
+	}
+
+	// should handle non-nil but empty
+	is = &terraform.InstanceState{}
+	is, err = resourceArmVirtualMachineScaleSetMigrateState(0, is, meta)
+	if err != nil {
+		t.Fatalf("err: %#v", err)
+	}
+}
+
+func TestLoggableVirtualMachineScaleSetAttributes(t *testing.T) {
+	attributes := map[string]string{
+		"name":                                         "acctvmss",
+		"admin_password":                               "Passwword1234",
+		"custom_data":                                  "custom data!",
+		"os_profile.2669702628.admin_password":         "Passwword1234",
+		"os_profile.2669702628.admin_username":         "myadmin",
+		"extension.1235434551.protected_settings":      "{\"storageAccountKey\":\"secret\"}",
+		"extension.1235434551.protected_settings_hash": "abc123",
+	}
+
+	expected := map[string]string{
+		"name":                                 "acctvmss",
+		"os_profile.2669702628.admin_username": "myadmin",
+		"extension.1235434551.protected_settings_hash": "abc123",
+	}
+
+	loggable := loggableVirtualMachineScaleSetAttributes(attributes)
+	if !reflect.DeepEqual(loggable, expected) {
+		t.Fatalf("bad:\n\n expected: %#v\n\n got: %#v", expected, loggable)
+	}
+
+	if len(attributes) != 7 {
+		t.Fatalf("Expected the attributes to be left unchanged, got %#v", attributes)
+	}
+}
@@ -1,107 +1,3230 @@
 package azurerm
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
+	"io/ioutil"
+	"log"
 	"net/http"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/arm/compute"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/hashicorp/terraform/config"
 	"github.com/hashicorp/terraform/helper/acctest"
 	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/terraform"
 )
 
-func TestAccAzureRMVirtualMachineScaleSet_basicLinux(t *testing.T) {
-	ri := acctest.RandInt()
-	config := fmt.Sprintf(testAccAzureRMVirtualMachineScaleSet_basicLinux, ri, ri, ri, ri, ri, ri, ri, ri)
-	resource.Test(t, resource.TestCase{
-		PreCheck:     func() { testAccPreCheck(t) },
-		Providers:    testAccProviders,
-		CheckDestroy: testCheckAzureRMVirtualMachineScaleSetDestroy,
-		Steps: []resource.TestStep{
-			{
-				Config: config,
-				Check: resource.ComposeTestCheckFunc(
-					testCheckAzureRMVirtualMachineScaleSetExists("azurerm_virtual_machine_scale_set.test"),
-				),
+func TestValidateArmVirtualMachineScaleSetCapacity(t *testing.T) {
+	testCases := []struct {
+		input       int
+		shouldError bool
+	}{
+		{-1, true},
+		{0, false},
+		{2, false},
+		{100, false},
+		{101, true},
+		{1000, true},
+	}
+
+	for _, test := range testCases {
+		_, es := validateArmVirtualMachineScaleSetCapacity(test.input, "capacity")
+
+		if test.shouldError && len(es) == 0 {
+			t.Fatalf("Expected validating capacity %d to fail", test.input)
+		}
+
+		if !test.shouldError && len(es) > 0 {
+			t.Fatalf("Expected validating capacity %d to pass: %v", test.input, es)
+		}
+	}
+}
+
+func TestExpandVirtualMachineScaleSetSku_singlePlacementGroup(t *testing.T) {
+	testCases := []struct {
+		capacity    int
+		shouldError bool
+	}{
+		{0, false},
+		{100, false},
+		{101, true},
+	}
+
+	for _, test := range testCases {
+		d := resourceArmVirtualMachineScaleSet().TestResourceData()
+		d.Set("sku", []interface{}{
+			map[string]interface{}{
+				"name":     "Standard_A0",
+				"tier":     "Standard",
+				"capacity": test.capacity,
+			},
+		})
+
+		_, err := expandVirtualMachineScaleSetSku(d)
+		if test.shouldError && err == nil {
+			t.Fatalf("Expected expanding a sku with capacity %d to fail", test.capacity)
+		}
+
+		if !test.shouldError && err != nil {
+			t.Fatalf("Expected expanding a sku with capacity %d to pass: %s", test.capacity, err)
+		}
+	}
+}
+
+func TestResourceArmVirtualMachineScaleSet_multipleSkus(t *testing.T) {
+	raw := map[string]interface{}{
+		"sku": []interface{}{
+			map[string]interface{}{
+				"name":     "Standard_A0",
+				"tier":     "Standard",
+				"capacity": 2,
+			},
+			map[string]interface{}{
+				"name":     "Standard_A1",
+				"tier":     "Standard",
+				"capacity": 2,
 			},
 		},
-	})
+	}
+
+	rawConfig, err := config.NewRawConfig(raw)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, es := resourceArmVirtualMachineScaleSet().Validate(terraform.NewResourceConfig(rawConfig))
+	for _, e := range es {
+		if strings.Contains(e.Error(), "sku: attribute supports 1 item maximum, config has 2 declared") {
+			return
+		}
+	}
+
+	t.Fatalf("Expected validating two sku blocks to fail with a MaxItems error, got: %v", es)
+}
+
+func TestFlattenAzureRmVirtualMachineScaleSetInstanceView(t *testing.T) {
+	codes := map[string]int32{
+		"ProvisioningState/succeeded": 3,
+		"ProvisioningState/creating":  1,
+		"PowerState/running":          3,
+	}
+
+	summaries := make([]compute.VirtualMachineStatusCodeCount, 0, len(codes))
+	for code, count := range codes {
+		code, count := code, count
+		summaries = append(summaries, compute.VirtualMachineStatusCodeCount{
+			Code:  &code,
+			Count: &count,
+		})
+	}
+
+	view := compute.VirtualMachineScaleSetInstanceView{
+		VirtualMachine: &compute.VirtualMachineScaleSetInstanceViewStatusesSummary{
+			StatusesSummary: &summaries,
+		},
+	}
+
+	instances, provisioningStates := flattenAzureRmVirtualMachineScaleSetInstanceView(view)
+	if instances != 4 {
+		t.Fatalf("Expected 4 instances, got %d", instances)
+	}
+
+	expected := map[string]interface{}{
+		"succeeded": "3",
+		"creating":  "1",
+	}
+	if !reflect.DeepEqual(provisioningStates, expected) {
+		t.Fatalf("Expected provisioning states %#v, got %#v", expected, provisioningStates)
+	}
+
+	instances, provisioningStates = flattenAzureRmVirtualMachineScaleSetInstanceView(compute.VirtualMachineScaleSetInstanceView{})
+	if instances != 0 || len(provisioningStates) != 0 {
+		t.Fatalf("Expected no instances for an empty instance view, got %d and %#v", instances, provisioningStates)
+	}
+}
+
+func TestResourceArmVirtualMachineScaleSetRead_instanceViewError(t *testing.T) {
+	id := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Compute/virtualMachineScaleSets/acctvmss"
+	scaleSet := fmt.Sprintf(`{
+		"id": %q,
+		"name": "acctvmss",
+		"location": "westus",
+		"sku": {"name": "Standard_A0", "tier": "Standard", "capacity": 2},
+		"properties": {
+			"provisioningState": "Succeeded",
+			"upgradePolicy": {"mode": "Manual"},
+			"virtualMachineProfile": {
+				"osProfile": {"computerNamePrefix": "testvm", "adminUsername": "myadmin"},
+				"storageProfile": {
+					"osDisk": {
+						"name": "osDiskProfile",
+						"caching": "ReadWrite",
+						"createOption": "FromImage",
+						"vhdContainers": ["https://acctestsa.blob.core.windows.net/vhds"]
+					}
+				},
+				"networkProfile": {"networkInterfaceConfigurations": []}
+			}
+		}
+	}`, id)
+
+	testCases := []struct {
+		statusCode  int
+		expectedId  string
+		description string
+	}{
+		{http.StatusBadRequest, id, "a failed instance view read keeps the scale set"},
+		{http.StatusNotFound, "", "a missing instance view removes the scale set"},
+	}
+
+	for _, test := range testCases {
+		vmssc := compute.NewVirtualMachineScaleSetsClient("00000000-0000-0000-0000-000000000000")
+		vmssc.Sender = autorest.SenderFunc(func(r *http.Request) (*http.Response, error) {
+			statusCode := http.StatusOK
+			body := scaleSet
+			if strings.HasSuffix(r.URL.Path, "/instanceView") {
+				statusCode = test.statusCode
+				body = `{"error": {"code": "BadRequest"}}`
+			}
+
+			return &http.Response{
+				StatusCode: statusCode,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+				Request:    r,
+			}, nil
+		})
+
+		d := resourceArmVirtualMachineScaleSet().TestResourceData()
+		d.SetId(id)
+
+		if err := resourceArmVirtualMachineScaleSetRead(d, &ArmClient{vmScaleSetClient: vmssc}); err != nil {
+			t.Fatalf("Expected %s without an error, got %s", test.description, err)
+		}
+
+		if d.Id() != test.expectedId {
+			t.Fatalf("Expected %s, got ID %q", test.description, d.Id())
+		}
+
+		if _, ok := d.GetOk("instances"); ok {
+			t.Fatalf("Expected instances to be left unset when the instance view cannot be read, got %v", d.Get("instances"))
+		}
+	}
+}
+
+func TestFlattenAzureRmVirtualMachineScaleSetSku_zeroCapacity(t *testing.T) {
+	name := "Standard_A0"
+	zero := int64(0)
+
+	for _, sku := range []*compute.Sku{
+		{Name: &name, Capacity: &zero},
+		{Name: &name},
+	} {
+		flattened := flattenAzureRmVirtualMachineScaleSetSku(sku)
+		result := flattened[0].(map[string]interface{})
+		if result["capacity"] != zero {
+			t.Fatalf("Expected a capacity of 0, got %v", result["capacity"])
+		}
+	}
+}
+
+func TestFlattenAzureRmVirtualMachineScaleSetSku(t *testing.T) {
+	name := "Standard_A0"
+	tier := "Standard"
+	capacity := int64(7)
+
+	testCases := []struct {
+		sku          *compute.Sku
+		expectedTier interface{}
+	}{
+		{&compute.Sku{Name: &name, Tier: &tier, Capacity: &capacity}, tier},
+		// Azure may omit the tier entirely
+		{&compute.Sku{Name: &name, Capacity: &capacity}, nil},
+	}
+
+	for _, test := range testCases {
+		flattened := flattenAzureRmVirtualMachineScaleSetSku(test.sku)
+		if len(flattened) != 1 {
+			t.Fatalf("Expected 1 sku, got %d", len(flattened))
+		}
+
+		result := flattened[0].(map[string]interface{})
+		if result["capacity"] != capacity {
+			t.Fatalf("Expected the live sku capacity %d, got %v", capacity, result["capacity"])
+		}
+
+		if result["tier"] != test.expectedTier {
+			t.Fatalf("Expected sku tier %v, got %v", test.expectedTier, result["tier"])
+		}
+	}
+}
+
+// testResourceArmVirtualMachineScaleSetRawConfig returns the raw configuration
+// of a minimal scale set, with the given computer name prefix and tags.
+func testResourceArmVirtualMachineScaleSetRawConfig(computerNamePrefix string, tags map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"name":                "acctvmss",
+		"location":            "westus",
+		"resource_group_name": "acctestrg",
+		"upgrade_policy_mode": "Manual",
+		"sku": []interface{}{
+			map[string]interface{}{
+				"name":     "Standard_A0",
+				"tier":     "Standard",
+				"capacity": 2,
+			},
+		},
+		"os_profile": []interface{}{
+			map[string]interface{}{
+				"computer_name_prefix": computerNamePrefix,
+				"admin_username":       "myadmin",
+				"admin_password":       "Passwword1234",
+			},
+		},
+		"network_profile": []interface{}{
+			map[string]interface{}{
+				"name":    "TestNetworkProfile",
+				"primary": true,
+				"ip_configuration": []interface{}{
+					map[string]interface{}{
+						"name":      "TestIPConfiguration",
+						"subnet_id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Network/virtualNetworks/acctvn/subnets/acctsub",
+					},
+				},
+			},
+		},
+		"storage_profile_os_disk": []interface{}{
+			map[string]interface{}{
+				"name":           "osDiskProfile",
+				"caching":        "ReadWrite",
+				"create_option":  "FromImage",
+				"vhd_containers": []interface{}{"https://acctestsa.blob.core.windows.net/vhds"},
+			},
+		},
+		"tags": tags,
+	}
+}
+
+func testResourceArmVirtualMachineScaleSetDiff(t *testing.T, before map[string]interface{}, after map[string]interface{}) (*terraform.InstanceState, *terraform.InstanceDiff) {
+	r := resourceArmVirtualMachineScaleSet()
+
+	beforeConfig, err := config.NewRawConfig(before)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	created, err := r.Diff(nil, terraform.NewResourceConfig(beforeConfig))
+	if err != nil {
+		t.Fatalf("Error diffing the initial configuration: %s", err)
+	}
+
+	state := &terraform.InstanceState{
+		ID:         "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Compute/virtualMachineScaleSets/acctvmss",
+		Attributes: make(map[string]string),
+	}
+	for k, v := range created.Attributes {
+		if !v.NewComputed {
+			state.Attributes[k] = v.New
+		}
+	}
+
+	// Read sets the extensions again, which stores their computed fields too
+	d := r.Data(state)
+	if err := d.Set("extension", d.Get("extension")); err != nil {
+		t.Fatalf("Error setting extensions: %s", err)
+	}
+	for k, v := range d.State().Attributes {
+		if strings.HasPrefix(k, "extension.") {
+			state.Attributes[k] = v
+		}
+	}
+
+	afterConfig, err := config.NewRawConfig(after)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	diff, err := r.Diff(state, terraform.NewResourceConfig(afterConfig))
+	if err != nil {
+		t.Fatalf("Error diffing the updated configuration: %s", err)
+	}
+
+	return state, diff
+}
+
+func TestResourceArmVirtualMachineScaleSet_skuTierRoundTrip(t *testing.T) {
+	name := "Standard_A0"
+	tier := "Standard"
+	capacity := int64(2)
+
+	for _, configTier := range []string{"", "Standard"} {
+		raw := testResourceArmVirtualMachineScaleSetRawConfig("testvm", map[string]interface{}{"environment": "Production"})
+		if configTier != "" {
+			raw["sku"].([]interface{})[0].(map[string]interface{})["tier"] = configTier
+		}
+		state, _ := testResourceArmVirtualMachineScaleSetDiff(t, raw, raw)
+
+		// Read stores the tier Azure returns
+		r := resourceArmVirtualMachineScaleSet()
+		d := r.Data(state)
+		if err := d.Set("sku", flattenAzureRmVirtualMachineScaleSetSku(&compute.Sku{Name: &name, Tier: &tier, Capacity: &capacity})); err != nil {
+			t.Fatalf("Error setting sku: %s", err)
+		}
+
+		rawConfig, err := config.NewRawConfig(raw)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		diff, err := r.Diff(d.State(), terraform.NewResourceConfig(rawConfig))
+		if err != nil {
+			t.Fatalf("Error diffing: %s", err)
+		}
+
+		if diff == nil {
+			continue
+		}
+
+		for k, v := range diff.Attributes {
+			if strings.HasPrefix(k, "sku.") {
+				t.Fatalf("Expected no sku diff after reading tier %q with configured tier %q, got %s: %#v", tier, configTier, k, v)
+			}
+		}
+	}
+}
+
+func TestResourceArmVirtualMachineScaleSet_osProfileRefresh(t *testing.T) {
+	computerNamePrefix := "testvm"
+	adminUsername := "myadmin"
+	customData := base64.StdEncoding.EncodeToString([]byte("#!/bin/bash\necho hello"))
+
+	testCases := []struct {
+		name             string
+		customData       string
+		imported         bool
+		expectedRecreate bool
+	}{
+		{"refresh with custom_data", customData, false, false},
+		{"refresh without custom_data", "", false, false},
+		{"import without custom_data", "", true, false},
+		// Azure does not return the custom data, so the first plan after an
+		// import which uses it replaces the scale set
+		{"import with custom_data", customData, true, true},
+	}
+
+	for _, test := range testCases {
+		raw := testResourceArmVirtualMachineScaleSetRawConfig(computerNamePrefix, map[string]interface{}{"environment": "Production"})
+		if test.customData != "" {
+			raw["os_profile"].([]interface{})[0].(map[string]interface{})["custom_data"] = test.customData
+		}
+
+		r := resourceArmVirtualMachineScaleSet()
+		state, _ := testResourceArmVirtualMachineScaleSetDiff(t, raw, raw)
+		if test.imported {
+			state = &terraform.InstanceState{
+				ID:         state.ID,
+				Attributes: map[string]string{"id": state.ID},
+			}
+		}
+
+		// Azure returns neither the admin password nor the custom data
+		d := r.Data(state)
+		if err := d.Set("os_profile", flattenAzureRMVirtualMachineScaleSetOsProfile(d, &compute.VirtualMachineScaleSetOSProfile{
+			ComputerNamePrefix: &computerNamePrefix,
+			AdminUsername:      &adminUsername,
+		})); err != nil {
+			t.Fatalf("%s: Error setting os_profile: %s", test.name, err)
+		}
+
+		rawConfig, err := config.NewRawConfig(raw)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		diff, err := r.Diff(d.State(), terraform.NewResourceConfig(rawConfig))
+		if err != nil {
+			t.Fatalf("%s: Error diffing: %s", test.name, err)
+		}
+
+		recreate := false
+		if diff != nil {
+			for k, v := range diff.Attributes {
+				if !strings.HasPrefix(k, "os_profile.") {
+					continue
+				}
+
+				// An imported scale set has no admin password in state, which can
+				// only be updated in place
+				if test.imported && !v.RequiresNew {
+					continue
+				}
+
+				if !test.expectedRecreate {
+					t.Fatalf("%s: Expected no os_profile diff which recreates the scale set, got %s: %#v", test.name, k, v)
+				}
+				recreate = true
+			}
+		}
+
+		if recreate != test.expectedRecreate {
+			t.Fatalf("%s: Expected the os_profile diff to recreate the scale set to be %t, got %t", test.name, test.expectedRecreate, recreate)
+		}
+	}
+}
+
+func TestResourceArmVirtualMachineScaleSet_tagsUpdateInPlace(t *testing.T) {
+	before := testResourceArmVirtualMachineScaleSetRawConfig("testvm", map[string]interface{}{"environment": "Production"})
+	after := testResourceArmVirtualMachineScaleSetRawConfig("testvm", map[string]interface{}{"environment": "Staging"})
+
+	_, diff := testResourceArmVirtualMachineScaleSetDiff(t, before, after)
+	if diff == nil || diff.Empty() {
+		t.Fatalf("Expected changing tags to produce a diff")
+	}
+
+	if diff.RequiresNew() {
+		t.Fatalf("Expected changing tags not to recreate the scale set: %#v", diff)
+	}
+}
+
+func TestResourceArmVirtualMachineScaleSet_computerNamePrefixForcesNew(t *testing.T) {
+	before := testResourceArmVirtualMachineScaleSetRawConfig("testvm", map[string]interface{}{"environment": "Production"})
+	after := testResourceArmVirtualMachineScaleSetRawConfig("othervm", map[string]interface{}{"environment": "Production"})
+
+	_, diff := testResourceArmVirtualMachineScaleSetDiff(t, before, after)
+	if diff == nil || !diff.RequiresNew() {
+		t.Fatalf("Expected changing the computer name prefix to recreate the scale set: %#v", diff)
+	}
+}
+
+func testResourceArmVirtualMachineScaleSetRawConfigWithExtension(tags map[string]interface{}, settings string) map[string]interface{} {
+	raw := testResourceArmVirtualMachineScaleSetRawConfig("testvm", tags)
+	raw["extension"] = []interface{}{
+		map[string]interface{}{
+			"name":                 "CustomScript",
+			"publisher":            "Microsoft.Azure.Extensions",
+			"type":                 "CustomScript",
+			"type_handler_version": "2.0",
+			"settings":             settings,
+		},
+	}
+	return raw
+}
+
+func TestResourceArmVirtualMachineScaleSet_protectedSettingsNoChurn(t *testing.T) {
+	testCases := []struct {
+		protectedSettings string
+		expectDiff        bool
+	}{
+		{`{"storageAccountKey": "secret"}`, false},
+		{`{ "storageAccountKey":"secret" }`, false},
+		{`{"storageAccountKey": "rotated"}`, true},
+	}
+
+	for _, test := range testCases {
+		before := testResourceArmVirtualMachineScaleSetRawConfigWithExtension(map[string]interface{}{"environment": "Production"}, `{"commandToExecute": "echo one"}`)
+		before["extension"].([]interface{})[0].(map[string]interface{})["protected_settings"] = `{"storageAccountKey": "secret"}`
+		state, _ := testResourceArmVirtualMachineScaleSetDiff(t, before, before)
+
+		// Read the extension back, as Azure returns it without the protected settings
+		extensionName := "CustomScript"
+		publisher := "Microsoft.Azure.Extensions"
+		extensionType := "CustomScript"
+		version := "2.0"
+		settings := map[string]interface{}{"commandToExecute": "echo one"}
+		r := resourceArmVirtualMachineScaleSet()
+		d := r.Data(state)
+		extensions, err := flattenAzureRmVirtualMachineScaleSetExtensionProfile(d, &compute.VirtualMachineScaleSetExtensionProfile{
+			Extensions: &[]compute.VirtualMachineScaleSetExtension{
+				{
+					Name: &extensionName,
+					Properties: &compute.VirtualMachineScaleSetExtensionProperties{
+						Publisher:          &publisher,
+						Type:               &extensionType,
+						TypeHandlerVersion: &version,
+						Settings:           &settings,
+					},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Error flattening extensions: %s", err)
+		}
+
+		if extensions[0]["protected_settings"] != `{"storageAccountKey":"secret"}` {
+			t.Fatalf("Expected the applied protected settings to be kept from state, got %v", extensions[0]["protected_settings"])
+		}
+
+		if extensions[0]["protected_settings_hash"] != virtualMachineScaleSetProtectedSettingsHash(`{"storageAccountKey": "secret"}`) {
+			t.Fatalf("Expected the protected settings hash to match the applied protected settings, got %v", extensions[0]["protected_settings_hash"])
+		}
+
+		if err := d.Set("extension", extensions); err != nil {
+			t.Fatalf("Error setting extensions: %s", err)
+		}
+
+		after := testResourceArmVirtualMachineScaleSetRawConfigWithExtension(map[string]interface{}{"environment": "Production"}, `{"commandToExecute": "echo one"}`)
+		after["extension"].([]interface{})[0].(map[string]interface{})["protected_settings"] = test.protectedSettings
+		afterConfig, err := config.NewRawConfig(after)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		diff, err := r.Diff(d.State(), terraform.NewResourceConfig(afterConfig))
+		if err != nil {
+			t.Fatalf("Error diffing: %s", err)
+		}
+
+		hasExtensionDiff := false
+		if diff != nil {
+			for k := range diff.Attributes {
+				if strings.HasPrefix(k, "extension.") {
+					hasExtensionDiff = true
+				}
+			}
+		}
+
+		if hasExtensionDiff != test.expectDiff {
+			t.Fatalf("Expected an extension diff to be %t for protected settings %s, got %t", test.expectDiff, test.protectedSettings, hasExtensionDiff)
+		}
+
+		if !test.expectDiff {
+			continue
+		}
+
+		// Apply the change and read the extension back, which must leave a
+		// single extension carrying the hash of the new protected settings
+		r.Update = func(d *schema.ResourceData, meta interface{}) error {
+			extensions, err := flattenAzureRmVirtualMachineScaleSetExtensionProfile(d, &compute.VirtualMachineScaleSetExtensionProfile{
+				Extensions: &[]compute.VirtualMachineScaleSetExtension{
+					{
+						Name: &extensionName,
+						Properties: &compute.VirtualMachineScaleSetExtensionProperties{
+							Publisher:          &publisher,
+							Type:               &extensionType,
+							TypeHandlerVersion: &version,
+							Settings:           &settings,
+						},
+					},
+				},
+			})
+			if err != nil {
+				return err
+			}
+			return d.Set("extension", extensions)
+		}
+
+		applied, err := r.Apply(d.State(), diff, nil)
+		if err != nil {
+			t.Fatalf("Error applying: %s", err)
+		}
+
+		if applied.Attributes["extension.#"] != "1" {
+			t.Fatalf("Expected a single extension after changing the protected settings, got %s", applied.Attributes["extension.#"])
+		}
+
+		expectedHash := virtualMachineScaleSetProtectedSettingsHash(test.protectedSettings)
+		found := false
+		for k, v := range applied.Attributes {
+			if strings.HasSuffix(k, ".protected_settings_hash") && v == expectedHash {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("Expected the protected settings hash %s in state, got %#v", expectedHash, applied.Attributes)
+		}
+	}
+}
+
+func TestResourceArmVirtualMachineScaleSet_extensionOnlyUpdate(t *testing.T) {
+	testCases := []struct {
+		afterTags              map[string]interface{}
+		expectedExtensionsOnly bool
+	}{
+		{map[string]interface{}{"environment": "Production"}, true},
+		{map[string]interface{}{"environment": "Staging"}, false},
+	}
+
+	for _, test := range testCases {
+		before := testResourceArmVirtualMachineScaleSetRawConfigWithExtension(map[string]interface{}{"environment": "Production"}, `{"commandToExecute": "echo one"}`)
+		after := testResourceArmVirtualMachineScaleSetRawConfigWithExtension(test.afterTags, `{"commandToExecute": "echo two"}`)
+		state, diff := testResourceArmVirtualMachineScaleSetDiff(t, before, after)
+
+		var extensionsOnly bool
+		var params *compute.VirtualMachineScaleSet
+		r := resourceArmVirtualMachineScaleSet()
+		r.Update = func(d *schema.ResourceData, meta interface{}) error {
+			extensionsOnly = resourceArmVirtualMachineScaleSetOnlyExtensionsChanged(d)
+
+			var err error
+			params, err = expandAzureRmVirtualMachineScaleSetExtensionsUpdate(d, testVirtualMachineScaleSetExisting())
+			return err
+		}
+
+		if _, err := r.Apply(state, diff, nil); err != nil {
+			t.Fatalf("Error applying the updated configuration: %s", err)
+		}
+
+		if extensionsOnly != test.expectedExtensionsOnly {
+			t.Fatalf("Expected only the extensions to have changed to be %t for tags %v, got %t", test.expectedExtensionsOnly, test.afterTags, extensionsOnly)
+		}
+
+		if !extensionsOnly {
+			continue
+		}
+
+		testCheckVirtualMachineScaleSetUpdateKeepsExisting(t, params)
+
+		profile := params.Properties.VirtualMachineProfile
+
+		extensions := *profile.ExtensionProfile.Extensions
+
+		if len(extensions) != 1 {
+			t.Fatalf("Expected 1 extension, got %d", len(extensions))
+		}
+
+		settings := *extensions[0].Properties.Settings
+		if settings["commandToExecute"] != "echo two" {
+			t.Fatalf("Expected the updated extension settings, got %v", settings)
+		}
+	}
+}
+
+// testVirtualMachineScaleSetExisting returns a scale set as Azure has it, for
+// testing updates which change only part of it.
+func testVirtualMachineScaleSetExisting() *compute.VirtualMachineScaleSet {
+	name := "acctvmss"
+	location := "westus"
+	environment := "Production"
+	skuName := "Standard_A0"
+	capacity := int64(2)
+	namePrefix := "testvm"
+	networkName := "TestNetworkProfile"
+	diskName := "osDiskProfile"
+
+	return &compute.VirtualMachineScaleSet{
+		Name:     &name,
+		Location: &location,
+		Tags:     &map[string]*string{"environment": &environment},
+		Sku:      &compute.Sku{Name: &skuName, Capacity: &capacity},
+		Properties: &compute.VirtualMachineScaleSetProperties{
+			UpgradePolicy: &compute.UpgradePolicy{Mode: compute.Manual},
+			VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+				OsProfile: &compute.VirtualMachineScaleSetOSProfile{ComputerNamePrefix: &namePrefix},
+				StorageProfile: &compute.VirtualMachineScaleSetStorageProfile{
+					OsDisk: &compute.VirtualMachineScaleSetOSDisk{Name: &diskName},
+				},
+				NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{
+					NetworkInterfaceConfigurations: &[]compute.VirtualMachineScaleSetNetworkConfiguration{
+						{Name: &networkName},
+					},
+				},
+			},
+		},
+	}
+}
+
+// testCheckVirtualMachineScaleSetUpdateKeepsExisting checks that an update
+// built from testVirtualMachineScaleSetExisting still sends the rest of the
+// scale set, as the PUT replaces whatever it leaves out.
+func testCheckVirtualMachineScaleSetUpdateKeepsExisting(t *testing.T, params *compute.VirtualMachineScaleSet) {
+	if params.Tags == nil || *(*params.Tags)["environment"] != "Production" {
+		t.Fatalf("Expected the update to keep the existing tags, got %#v", params.Tags)
+	}
+
+	if params.Sku == nil || *params.Sku.Name != "Standard_A0" {
+		t.Fatalf("Expected the update to keep the existing sku, got %#v", params.Sku)
+	}
+
+	if params.Properties.UpgradePolicy == nil || params.Properties.UpgradePolicy.Mode != compute.Manual {
+		t.Fatalf("Expected the update to keep the existing upgrade policy, got %#v", params.Properties.UpgradePolicy)
+	}
+
+	profile := params.Properties.VirtualMachineProfile
+	if profile.OsProfile == nil || profile.StorageProfile == nil || profile.NetworkProfile == nil {
+		t.Fatalf("Expected the update to keep the existing os, storage and network profiles, got %#v", profile)
+	}
+}
+
+func TestVirtualMachineScaleSetGetForUpdate(t *testing.T) {
+	client := testVirtualMachineScaleSetClientReturning(http.StatusOK, `{
+		"name": "acctvmss",
+		"location": "westus",
+		"tags": {"environment": "Production"},
+		"sku": {"name": "Standard_A0", "capacity": 2},
+		"properties": {
+			"provisioningState": "Succeeded",
+			"upgradePolicy": {"mode": "Manual"},
+			"virtualMachineProfile": {
+				"osProfile": {"computerNamePrefix": "testvm"},
+				"storageProfile": {"osDisk": {"name": "osDiskProfile"}},
+				"networkProfile": {"networkInterfaceConfigurations": [{"name": "TestNetworkProfile"}]}
+			}
+		}
+	}`)
+
+	scaleSet, err := virtualMachineScaleSetGetForUpdate(client.vmScaleSetClient, "acctestrg", "acctvmss")
+	if err != nil {
+		t.Fatalf("Error reading the scale set: %s", err)
+	}
+
+	testCheckVirtualMachineScaleSetUpdateKeepsExisting(t, scaleSet)
+
+	if scaleSet.Properties.ProvisioningState != nil {
+		t.Fatalf("Expected the read-only provisioning state not to be sent back, got %q", *scaleSet.Properties.ProvisioningState)
+	}
+
+	client = testVirtualMachineScaleSetClientReturning(http.StatusOK, `{"name": "acctvmss"}`)
+	if _, err := virtualMachineScaleSetGetForUpdate(client.vmScaleSetClient, "acctestrg", "acctvmss"); err == nil {
+		t.Fatalf("Expected reading a scale set without a virtual machine profile to fail")
+	}
+}
+
+func TestValidateArmVirtualMachineScaleSetUpgradePolicyMode(t *testing.T) {
+	testCases := []struct {
+		input         string
+		shouldError   bool
+		errorContains string
+	}{
+		{"Automatic", false, ""},
+		{"Manual", false, ""},
+		{"manual", true, "can only be Automatic or Manual"},
+		{"Manual ", true, "can only be Automatic or Manual"},
+		{"Rolling", true, "2016-03-30 compute API version"},
+		{"Rolling ", true, "can only be Automatic or Manual"},
+		{"", true, "can only be Automatic or Manual"},
+	}
+
+	for _, test := range testCases {
+		_, es := validateArmVirtualMachineScaleSetUpgradePolicyMode(test.input, "upgrade_policy_mode")
+
+		if test.shouldError && len(es) == 0 {
+			t.Fatalf("Expected validating upgrade_policy_mode %q to fail", test.input)
+		}
+
+		if !test.shouldError && len(es) > 0 {
+			t.Fatalf("Expected validating upgrade_policy_mode %q to pass: %v", test.input, es)
+		}
+
+		if test.shouldError && !strings.Contains(es[0].Error(), test.errorContains) {
+			t.Fatalf("Expected validating upgrade_policy_mode %q to fail with %q, got: %v", test.input, test.errorContains, es[0])
+		}
+	}
+}
+
+func TestValidateArmVirtualMachineScaleSetSkuTier(t *testing.T) {
+	testCases := []struct {
+		input       string
+		shouldError bool
+	}{
+		{"Standard", false},
+		{"Basic", false},
+		{"standard", true},
+		{"Premium", true},
+		{"", true},
+	}
+
+	for _, test := range testCases {
+		_, es := validateArmVirtualMachineScaleSetSkuTier(test.input, "tier")
+
+		if test.shouldError && len(es) == 0 {
+			t.Fatalf("Expected validating tier %q to fail", test.input)
+		}
+
+		if !test.shouldError && len(es) > 0 {
+			t.Fatalf("Expected validating tier %q to pass: %v", test.input, es)
+		}
+	}
+}
+
+func TestValidateArmVirtualMachineScaleSetTags(t *testing.T) {
+	testCases := []struct {
+		tags        map[string]interface{}
+		shouldError bool
+	}{
+		{map[string]interface{}{"Environment": "Production", "Owner": "ops"}, false},
+		{map[string]interface{}{"Environment": "Production", "environment": "Staging"}, true},
+		{map[string]interface{}{"environment": strings.Repeat("a", 257)}, true},
+	}
+
+	for _, test := range testCases {
+		_, es := validateArmVirtualMachineScaleSetTags(test.tags, "tags")
+
+		if test.shouldError && len(es) == 0 {
+			t.Fatalf("Expected validating tags %v to fail", test.tags)
+		}
+
+		if !test.shouldError && len(es) > 0 {
+			t.Fatalf("Expected validating tags %v to pass: %v", test.tags, es)
+		}
+	}
+
+	_, es := validateArmVirtualMachineScaleSetTags(map[string]interface{}{"Environment": "Production", "environment": "Staging"}, "tags")
+	if len(es) != 1 || !strings.Contains(es[0].Error(), "case-insensitive") {
+		t.Fatalf("Expected one error for keys which only differ in case, got %v", es)
+	}
+}
+
+func TestFlattenAzureRmVirtualMachineScaleSetTags(t *testing.T) {
+	production := "Production"
+	owner := "ops"
+	tagsMap := map[string]*string{
+		"environment": &production,
+		"Owner":       &owner,
+	}
+
+	existing := map[string]interface{}{
+		"Environment": "Production",
+	}
+
+	flattened := flattenAzureRmVirtualMachineScaleSetTags(&tagsMap, existing)
+
+	if len(flattened) != 2 {
+		t.Fatalf("Expected 2 results in flattened tag map, got %d", len(flattened))
+	}
+
+	if flattened["Environment"] != "Production" {
+		t.Fatalf("Expected the configured casing of the key Environment to be kept, got %v", flattened)
+	}
+
+	if flattened["Owner"] != "ops" {
+		t.Fatalf("Expected the key Owner to be flattened as returned, got %v", flattened)
+	}
+}
+
+func TestValidateArmVirtualMachineScaleSetCaching(t *testing.T) {
+	testCases := []struct {
+		input       string
+		shouldError bool
+	}{
+		{"None", false},
+		{"ReadOnly", false},
+		{"ReadWrite", false},
+		{"readwrite", true},
+		{"Read/Write", true},
+		{"", true},
+	}
+
+	for _, test := range testCases {
+		_, es := validateArmVirtualMachineScaleSetCaching(test.input, "caching")
+
+		if test.shouldError && len(es) == 0 {
+			t.Fatalf("Expected validating caching %q to fail", test.input)
+		}
+
+		if !test.shouldError && len(es) > 0 {
+			t.Fatalf("Expected validating caching %q to pass: %v", test.input, es)
+		}
+	}
+}
+
+func TestValidateArmVirtualMachineScaleSetAdminPassword(t *testing.T) {
+	testCases := []struct {
+		input         string
+		errorContains string
+	}{
+		{"Passwword1234", ""},
+		{"passw0rd!word", ""},
+		{"PASSWORD-1234", ""},
+		{"Password!1234", ""},
+		{"Pa55word!", "between 12 and 72 characters"},
+		{"Pa55word!" + strings.Repeat("x", 64), "between 12 and 72 characters"},
+		{"passwordpassword", "missing digit, special, uppercase"},
+		{"passwordPASSWORD", "missing digit, special"},
+		{"password12345678", "missing special, uppercase"},
+	}
+
+	for _, test := range testCases {
+		_, es := validateArmVirtualMachineScaleSetAdminPassword(test.input, "admin_password")
+
+		if test.errorContains == "" {
+			if len(es) > 0 {
+				t.Fatalf("Expected validating admin_password %q to pass: %v", test.input, es)
+			}
+			continue
+		}
+
+		if len(es) == 0 {
+			t.Fatalf("Expected validating admin_password %q to fail", test.input)
+		}
+
+		if !strings.Contains(es[0].Error(), test.errorContains) {
+			t.Fatalf("Expected validating admin_password %q to fail with %q, got: %v", test.input, test.errorContains, es[0])
+		}
+	}
+}
+
+func TestResourceArmVirtualMachineScaleSet_adminPasswordValidation(t *testing.T) {
+	testCases := []struct {
+		password    string
+		shouldError bool
+	}{
+		{"Passwword1234", false},
+		{"Pass1234", true},
+		{"passwordpassword", true},
+	}
+
+	for _, test := range testCases {
+		raw := testResourceArmVirtualMachineScaleSetRawConfig("testvm", map[string]interface{}{"environment": "Production"})
+		raw["os_profile"].([]interface{})[0].(map[string]interface{})["admin_password"] = test.password
+		rawConfig, err := config.NewRawConfig(raw)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		_, es := resourceArmVirtualMachineScaleSet().Validate(terraform.NewResourceConfig(rawConfig))
+		if test.shouldError && len(es) == 0 {
+			t.Fatalf("Expected planning admin_password %q to fail", test.password)
+		}
+
+		if !test.shouldError && len(es) > 0 {
+			t.Fatalf("Expected planning admin_password %q to pass: %v", test.password, es)
+		}
+	}
+}
+
+func TestExpandAzureRMVirtualMachineScaleSetsOsProfile_adminPassword(t *testing.T) {
+	// The password is validated when planning, so an update sends the one in
+	// config as it is
+	for _, password := range []string{"Passwword1234", "password"} {
+		d := resourceArmVirtualMachineScaleSet().TestResourceData()
+		d.Set("os_profile", []interface{}{
+			map[string]interface{}{
+				"computer_name_prefix": "testvm",
+				"admin_username":       "myadmin",
+				"admin_password":       password,
+			},
+		})
+
+		osProfile, err := expandAzureRMVirtualMachineScaleSetsOsProfile(d)
+		if err != nil {
+			t.Fatalf("Expected expanding admin_password %q to pass: %s", password, err)
+		}
+
+		if osProfile.AdminPassword == nil || *osProfile.AdminPassword != password {
+			t.Fatalf("Expected the admin password %q to be sent, got %v", password, osProfile.AdminPassword)
+		}
+	}
+}
+
+func TestExpandAzureRmVirtualMachineScaleSetOsProfileSecrets(t *testing.T) {
+	sourceVaultId := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.KeyVault/vaults/acctestkv"
+	certificateUrl := "https://acctestkv.vault.azure.net/secrets/acctestcert/0000000000000000"
+
+	testCases := []struct {
+		osConfig         string
+		certificateStore string
+		shouldError      bool
+	}{
+		{"os_profile_windows_config", "My", false},
+		{"os_profile_windows_config", "", true},
+		{"os_profile_linux_config", "", false},
+		{"os_profile_linux_config", "My", true},
+	}
+
+	for _, test := range testCases {
+		d := resourceArmVirtualMachineScaleSet().TestResourceData()
+		if test.osConfig == "os_profile_windows_config" {
+			d.Set("os_profile_windows_config", []interface{}{
+				map[string]interface{}{
+					"provision_vm_agent": true,
+				},
+			})
+		} else {
+			d.Set("os_profile_linux_config", []interface{}{
+				map[string]interface{}{
+					"disable_password_authentication": true,
+				},
+			})
+		}
+		if err := d.Set("os_profile_secrets", []interface{}{
+			map[string]interface{}{
+				"source_vault_id": sourceVaultId,
+				"vault_certificates": schema.NewSet(resourceArmVirtualMachineScaleSetVaultCertificatesHash, []interface{}{
+					map[string]interface{}{
+						"certificate_url":   certificateUrl,
+						"certificate_store": test.certificateStore,
+					},
+				}),
+			},
+		}); err != nil {
+			t.Fatalf("Error setting os_profile_secrets: %s", err)
+		}
+
+		secrets, err := expandAzureRmVirtualMachineScaleSetOsProfileSecrets(d)
+		if test.shouldError {
+			if err == nil {
+				t.Fatalf("Expected expanding a certificate_store of %q with %s to fail", test.certificateStore, test.osConfig)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Expected expanding a certificate_store of %q with %s to pass: %s", test.certificateStore, test.osConfig, err)
+		}
+
+		if len(*secrets) != 1 {
+			t.Fatalf("Expected 1 secret, got %d", len(*secrets))
+		}
+
+		secret := (*secrets)[0]
+		if *secret.SourceVault.ID != sourceVaultId {
+			t.Fatalf("Expected source vault %q, got %q", sourceVaultId, *secret.SourceVault.ID)
+		}
+
+		if len(*secret.VaultCertificates) != 1 {
+			t.Fatalf("Expected 1 vault certificate, got %d", len(*secret.VaultCertificates))
+		}
+
+		cert := (*secret.VaultCertificates)[0]
+		if *cert.CertificateURL != certificateUrl {
+			t.Fatalf("Expected certificate_url %q, got %q", certificateUrl, *cert.CertificateURL)
+		}
+
+		if test.certificateStore == "" && cert.CertificateStore != nil {
+			t.Fatalf("Expected no certificate_store, got %q", *cert.CertificateStore)
+		}
+
+		if test.certificateStore != "" && (cert.CertificateStore == nil || *cert.CertificateStore != test.certificateStore) {
+			t.Fatalf("Expected certificate_store %q, got %v", test.certificateStore, cert.CertificateStore)
+		}
+	}
+}
+
+func TestFlattenAzureRmVirtualMachineScaleSetOsProfileSecrets(t *testing.T) {
+	sourceVaultId := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.KeyVault/vaults/acctestkv"
+	certificateUrl := "https://acctestkv.vault.azure.net/secrets/acctestcert/0000000000000000"
+	certificateStore := "My"
+
+	secrets := []compute.VaultSecretGroup{
+		{
+			SourceVault: &compute.SubResource{
+				ID: &sourceVaultId,
+			},
+			VaultCertificates: &[]compute.VaultCertificate{
+				{
+					CertificateURL:   &certificateUrl,
+					CertificateStore: &certificateStore,
+				},
+			},
+		},
+	}
+
+	d := resourceArmVirtualMachineScaleSet().TestResourceData()
+	if err := d.Set("os_profile_secrets", flattenAzureRmVirtualMachineScaleSetOsProfileSecrets(&secrets)); err != nil {
+		t.Fatalf("Error setting os_profile_secrets: %s", err)
+	}
+
+	flattened := d.Get("os_profile_secrets").(*schema.Set).List()
+	if len(flattened) != 1 {
+		t.Fatalf("Expected 1 secret, got %d", len(flattened))
+	}
+
+	secret := flattened[0].(map[string]interface{})
+	if secret["source_vault_id"] != sourceVaultId {
+		t.Fatalf("Expected source_vault_id %q, got %q", sourceVaultId, secret["source_vault_id"])
+	}
+
+	certs := secret["vault_certificates"].(*schema.Set).List()
+	if len(certs) != 1 {
+		t.Fatalf("Expected 1 vault certificate, got %d", len(certs))
+	}
+
+	cert := certs[0].(map[string]interface{})
+	if cert["certificate_url"] != certificateUrl || cert["certificate_store"] != certificateStore {
+		t.Fatalf("Expected vault certificate %q in store %q, got %#v", certificateUrl, certificateStore, cert)
+	}
+}
+
+func TestExpandAzureRmVirtualMachineScaleSetApplicationHealthExtension(t *testing.T) {
+	testCases := []struct {
+		protocol    string
+		port        int
+		requestPath string
+		isWindows   bool
+		shouldError bool
+	}{
+		{"http", 8080, "/health", false, false},
+		{"https", 443, "/health", true, false},
+		{"tcp", 22, "", false, false},
+		{"http", 8080, "", false, true},
+		{"https", 443, "", false, true},
+		{"tcp", 22, "/health", false, true},
+	}
+
+	for _, test := range testCases {
+		config := map[string]interface{}{
+			"protocol":     test.protocol,
+			"port":         test.port,
+			"request_path": test.requestPath,
+		}
+
+		extension, err := expandAzureRmVirtualMachineScaleSetApplicationHealthExtension(config, test.isWindows)
+		if test.shouldError {
+			if err == nil {
+				t.Fatalf("Expected expanding a %s health probe with request_path %q to fail", test.protocol, test.requestPath)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Expected expanding a %s health probe with request_path %q to pass: %s", test.protocol, test.requestPath, err)
+		}
+
+		expectedType := "ApplicationHealthLinux"
+		if test.isWindows {
+			expectedType = "ApplicationHealthWindows"
+		}
+		if *extension.Properties.Type != expectedType {
+			t.Fatalf("Expected extension type %q, got %q", expectedType, *extension.Properties.Type)
+		}
+
+		settings := *extension.Properties.Settings
+		if settings["protocol"] != test.protocol || settings["port"] != test.port {
+			t.Fatalf("Expected a %s health probe on port %d, got settings %#v", test.protocol, test.port, settings)
+		}
+
+		if _, ok := settings["requestPath"]; ok != (test.requestPath != "") {
+			t.Fatalf("Expected requestPath %q, got settings %#v", test.requestPath, settings)
+		}
+	}
+}
+
+func TestFlattenAzureRmVirtualMachineScaleSetApplicationHealthExtension(t *testing.T) {
+	config := map[string]interface{}{
+		"protocol":     "http",
+		"port":         8080,
+		"request_path": "/health",
+	}
+	healthExtension, err := expandAzureRmVirtualMachineScaleSetApplicationHealthExtension(config, false)
+	if err != nil {
+		t.Fatalf("Error expanding application_health_extension: %s", err)
+	}
+
+	// settings are returned by Azure as decoded JSON, so numbers are float64
+	(*healthExtension.Properties.Settings)["port"] = float64(8080)
+
+	name := "CustomScript"
+	publisher := "Microsoft.OSTCExtensions"
+	extensionType := "CustomScriptForLinux"
+	version := "1.2"
+	profile := &compute.VirtualMachineScaleSetExtensionProfile{
+		Extensions: &[]compute.VirtualMachineScaleSetExtension{
+			{
+				Name: &name,
+				Properties: &compute.VirtualMachineScaleSetExtensionProperties{
+					Publisher:          &publisher,
+					Type:               &extensionType,
+					TypeHandlerVersion: &version,
+				},
+			},
+			*healthExtension,
+		},
+	}
+
+	flattened := flattenAzureRmVirtualMachineScaleSetApplicationHealthExtension(profile)
+	if len(flattened) != 1 {
+		t.Fatalf("Expected 1 application_health_extension, got %d", len(flattened))
+	}
+
+	result := flattened[0].(map[string]interface{})
+	if result["protocol"] != "http" || result["port"] != 8080 || result["request_path"] != "/health" {
+		t.Fatalf("Expected an http health probe on port 8080 at /health, got %#v", result)
+	}
+
+	d := resourceArmVirtualMachineScaleSet().TestResourceData()
+	extensions, err := flattenAzureRmVirtualMachineScaleSetExtensionProfile(d, profile)
+	if err != nil {
+		t.Fatalf("Error flattening extension profile: %s", err)
+	}
+
+	if len(extensions) != 1 || extensions[0]["name"] != name {
+		t.Fatalf("Expected only the %s extension to be flattened into extension, got %#v", name, extensions)
+	}
+}
+
+func TestValidateArmLoadBalancerInboundNatPoolId(t *testing.T) {
+	testCases := []struct {
+		input       string
+		shouldError bool
+	}{
+		{"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Network/loadBalancers/acctestlb/inboundNatPools/ssh", false},
+		{"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Network/loadBalancers/acctestlb/backendAddressPools/pool", true},
+		{"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg", true},
+		{"inboundNatPools/ssh", true},
+	}
+
+	for _, test := range testCases {
+		_, es := validateArmLoadBalancerInboundNatPoolId(test.input, "load_balancer_inbound_nat_pool_ids")
+
+		if test.shouldError && len(es) == 0 {
+			t.Fatalf("Expected validating load_balancer_inbound_nat_pool_ids %q to fail", test.input)
+		}
+
+		if !test.shouldError && len(es) > 0 {
+			t.Fatalf("Expected validating load_balancer_inbound_nat_pool_ids %q to pass: %v", test.input, es)
+		}
+	}
+}
+
+func TestFlattenAzureRmVirtualMachineScaleSetNetworkProfile_applicationGatewayPools(t *testing.T) {
+	networkName := "TestNetworkProfile"
+	ipConfigName := "TestIPConfiguration"
+	subnetId := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Network/virtualNetworks/acctvn/subnets/acctsub"
+	poolId := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Network/applicationGateways/acctag/backendAddressPools/pool"
+	primary := true
+
+	flattened := flattenAzureRmVirtualMachineScaleSetNetworkProfile(&compute.VirtualMachineScaleSetNetworkProfile{
+		NetworkInterfaceConfigurations: &[]compute.VirtualMachineScaleSetNetworkConfiguration{
+			{
+				Name: &networkName,
+				Properties: &compute.VirtualMachineScaleSetNetworkConfigurationProperties{
+					Primary: &primary,
+					IPConfigurations: &[]compute.VirtualMachineScaleSetIPConfiguration{
+						{
+							Name: &ipConfigName,
+							Properties: &compute.VirtualMachineScaleSetIPConfigurationProperties{
+								Subnet: &compute.APIEntityReference{
+									ID: &subnetId,
+								},
+								ApplicationGatewayBackendAddressPools: &[]compute.SubResource{
+									{ID: &poolId},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+
+	if len(flattened) != 1 {
+		t.Fatalf("Expected 1 network profile, got %d", len(flattened))
+	}
+
+	ipConfigs := flattened[0]["ip_configuration"].([]map[string]interface{})
+	if len(ipConfigs) != 1 {
+		t.Fatalf("Expected 1 ip configuration, got %d", len(ipConfigs))
+	}
+
+	pools := ipConfigs[0]["application_gateway_backend_address_pool_ids"].(*schema.Set)
+	if pools.Len() != 1 || !pools.Contains(poolId) {
+		t.Fatalf("Expected application gateway backend address pools to contain %q, got %#v", poolId, pools.List())
+	}
+
+	if _, ok := ipConfigs[0]["load_balancer_backend_address_pool_ids"]; ok {
+		t.Fatalf("Expected no load balancer backend address pools to be set when the API returns none")
+	}
+}
+
+func TestFlattenAzureRmVirtualMachineScaleSetNetworkProfile_ids(t *testing.T) {
+	networkName := "TestNetworkProfile"
+	networkId := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Compute/virtualMachineScaleSets/acctvmss/networkInterfaceConfigurations/TestNetworkProfile"
+	ipConfigName := "TestIPConfiguration"
+	subnetId := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Network/virtualNetworks/acctvn/subnets/acctsub"
+	primary := true
+
+	for _, id := range []*string{&networkId, nil} {
+		flattened := flattenAzureRmVirtualMachineScaleSetNetworkProfile(&compute.VirtualMachineScaleSetNetworkProfile{
+			NetworkInterfaceConfigurations: &[]compute.VirtualMachineScaleSetNetworkConfiguration{
+				{
+					ID:   id,
+					Name: &networkName,
+					Properties: &compute.VirtualMachineScaleSetNetworkConfigurationProperties{
+						Primary: &primary,
+						IPConfigurations: &[]compute.VirtualMachineScaleSetIPConfiguration{
+							{
+								Name: &ipConfigName,
+								Properties: &compute.VirtualMachineScaleSetIPConfigurationProperties{
+									Subnet: &compute.APIEntityReference{
+										ID: &subnetId,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+
+		if id == nil {
+			if _, ok := flattened[0]["id"]; ok {
+				t.Fatalf("Expected no id to be set when the API returns none, got %v", flattened[0]["id"])
+			}
+		} else if flattened[0]["id"] != networkId {
+			t.Fatalf("Expected the network profile id to be %q, got %v", networkId, flattened[0]["id"])
+		}
+
+		// Storing the id on Read must not show as a change to the configured profile
+		raw := testResourceArmVirtualMachineScaleSetRawConfig("testvm", map[string]interface{}{"environment": "Production"})
+		state, _ := testResourceArmVirtualMachineScaleSetDiff(t, raw, raw)
+
+		r := resourceArmVirtualMachineScaleSet()
+		d := r.Data(state)
+		if err := d.Set("network_profile", flattened); err != nil {
+			t.Fatalf("Error setting network_profile: %s", err)
+		}
+
+		rawConfig, err := config.NewRawConfig(raw)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		diff, err := r.Diff(d.State(), terraform.NewResourceConfig(rawConfig))
+		if err != nil {
+			t.Fatalf("Error diffing the configuration: %s", err)
+		}
+
+		if diff != nil {
+			for k, v := range diff.Attributes {
+				if strings.HasPrefix(k, "network_profile.") {
+					t.Fatalf("Expected no network_profile diff, got %s: %#v", k, v)
+				}
+			}
+		}
+
+		if id != nil && d.State().Attributes[fmt.Sprintf("network_profile.%d.id", resourceArmVirtualMachineScaleSetNetworkConfigurationHash(d.Get("network_profile").(*schema.Set).List()[0]))] != networkId {
+			t.Fatalf("Expected the network profile id to be stored in state, got %#v", d.State().Attributes)
+		}
+	}
+}
+
+func TestAzureRmVirtualMachineScaleSetNetworkProfile_multipleIPConfigurations(t *testing.T) {
+	subnetId := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Network/virtualNetworks/acctvn/subnets/acctsub"
+	frontendPoolId := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Network/loadBalancers/acctlb/backendAddressPools/frontend"
+	backendPoolId := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Network/loadBalancers/acctlb/backendAddressPools/backend"
+	natPoolId := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Network/loadBalancers/acctlb/inboundNatPools/ssh"
+
+	profile := map[string]interface{}{
+		"name":    "TestNetworkProfile",
+		"primary": true,
+		"ip_configuration": []interface{}{
+			map[string]interface{}{
+				"name":                                   "frontend",
+				"subnet_id":                              subnetId,
+				"load_balancer_backend_address_pool_ids": schema.NewSet(schema.HashString, []interface{}{frontendPoolId}),
+				"load_balancer_inbound_nat_pool_ids":     schema.NewSet(schema.HashString, []interface{}{natPoolId}),
+			},
+			map[string]interface{}{
+				"name":                                   "backend",
+				"subnet_id":                              subnetId,
+				"load_balancer_backend_address_pool_ids": schema.NewSet(schema.HashString, []interface{}{backendPoolId}),
+			},
+		},
+	}
+
+	d := resourceArmVirtualMachineScaleSet().TestResourceData()
+	if err := d.Set("network_profile", []interface{}{profile}); err != nil {
+		t.Fatalf("Error setting network_profile: %s", err)
+	}
+	configured := d.Get("network_profile").(*schema.Set).List()[0]
+
+	expanded, err := expandAzureRmVirtualMachineScaleSetNetworkProfile(d)
+	if err != nil {
+		t.Fatalf("Error expanding network_profile: %s", err)
+	}
+
+	ipConfigurations := *(*expanded.NetworkInterfaceConfigurations)[0].Properties.IPConfigurations
+	if len(ipConfigurations) != 2 {
+		t.Fatalf("Expected 2 ip configurations, got %d", len(ipConfigurations))
+	}
+
+	frontend := ipConfigurations[0].Properties
+	if *ipConfigurations[0].Name != "frontend" || len(*frontend.LoadBalancerBackendAddressPools) != 1 || *(*frontend.LoadBalancerBackendAddressPools)[0].ID != frontendPoolId {
+		t.Fatalf("Expected the first ip configuration to be frontend in pool %q, got %s with %#v", frontendPoolId, *ipConfigurations[0].Name, frontend.LoadBalancerBackendAddressPools)
+	}
+	if len(*frontend.LoadBalancerInboundNatPools) != 1 {
+		t.Fatalf("Expected the frontend ip configuration to have 1 inbound NAT pool, got %d", len(*frontend.LoadBalancerInboundNatPools))
+	}
+
+	backend := ipConfigurations[1].Properties
+	if *ipConfigurations[1].Name != "backend" || len(*backend.LoadBalancerBackendAddressPools) != 1 || *(*backend.LoadBalancerBackendAddressPools)[0].ID != backendPoolId {
+		t.Fatalf("Expected the second ip configuration to be backend in pool %q, got %s with %#v", backendPoolId, *ipConfigurations[1].Name, backend.LoadBalancerBackendAddressPools)
+	}
+	if len(*backend.LoadBalancerInboundNatPools) != 0 {
+		t.Fatalf("Expected the backend ip configuration to have no inbound NAT pools, got %d", len(*backend.LoadBalancerInboundNatPools))
+	}
+
+	flattened := flattenAzureRmVirtualMachineScaleSetNetworkProfile(expanded)
+	if err := d.Set("network_profile", flattened); err != nil {
+		t.Fatalf("Error setting the flattened network_profile: %s", err)
+	}
+
+	// The flattened profile must hash the same as the configured one, or it
+	// would show as a change
+	profiles := d.Get("network_profile").(*schema.Set).List()
+	if len(profiles) != 1 {
+		t.Fatalf("Expected 1 network profile after flattening, got %d", len(profiles))
+	}
+
+	if resourceArmVirtualMachineScaleSetNetworkConfigurationHash(profiles[0]) != resourceArmVirtualMachineScaleSetNetworkConfigurationHash(configured) {
+		t.Fatalf("Expected the flattened network profile to match the configured one, got %#v", profiles[0])
+	}
+}
+
+func TestValidateJsonString(t *testing.T) {
+	testCases := []struct {
+		input       string
+		shouldError bool
+	}{
+		{"", false},
+		{`{}`, false},
+		{`{"commandToExecute": "echo hello"}`, false},
+		{`{"fileUris": ["https://example.com/script.sh"], "commandToExecute": "bash script.sh"}`, false},
+		{`{"commandToExecute": }`, true},
+		{`["not", "an", "object"]`, true},
+		{`not json`, true},
+	}
+
+	for _, test := range testCases {
+		_, es := validateJsonString(test.input, "settings")
+
+		if test.shouldError && len(es) == 0 {
+			t.Fatalf("Expected validating settings %q to fail", test.input)
+		}
+
+		if !test.shouldError && len(es) > 0 {
+			t.Fatalf("Expected validating settings %q to pass: %v", test.input, es)
+		}
+	}
+}
+
+func TestResourceArmVirtualMachineScaleSetNetworkConfigurationHash(t *testing.T) {
+	networkProfile := func(subnetId string, pools ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"name":    "TestNetworkProfile",
+			"primary": true,
+			"ip_configuration": []interface{}{
+				map[string]interface{}{
+					"name":                                   "TestIPConfiguration",
+					"subnet_id":                              subnetId,
+					"load_balancer_backend_address_pool_ids": schema.NewSet(schema.HashString, pools),
+				},
+			},
+		}
+	}
+
+	subnetOne := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Network/virtualNetworks/acctvn/subnets/one"
+	subnetTwo := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Network/virtualNetworks/acctvn/subnets/two"
+	poolOne := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Network/loadBalancers/acctlb/backendAddressPools/one"
+	poolTwo := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Network/loadBalancers/acctlb/backendAddressPools/two"
+
+	if resourceArmVirtualMachineScaleSetNetworkConfigurationHash(networkProfile(subnetOne)) == resourceArmVirtualMachineScaleSetNetworkConfigurationHash(networkProfile(subnetTwo)) {
+		t.Fatalf("Expected network profiles differing only in subnet to have distinct hashes")
+	}
+
+	if resourceArmVirtualMachineScaleSetNetworkConfigurationHash(networkProfile(subnetOne, poolOne)) == resourceArmVirtualMachineScaleSetNetworkConfigurationHash(networkProfile(subnetOne, poolTwo)) {
+		t.Fatalf("Expected network profiles differing only in backend address pools to have distinct hashes")
+	}
+
+	if resourceArmVirtualMachineScaleSetNetworkConfigurationHash(networkProfile(subnetOne, poolOne, poolTwo)) != resourceArmVirtualMachineScaleSetNetworkConfigurationHash(networkProfile(subnetOne, poolTwo, poolOne)) {
+		t.Fatalf("Expected the network profile hash not to depend on backend address pool ordering")
+	}
+}
+
+func TestExpandAzureRmVirtualMachineScaleSetNetworkProfile_primary(t *testing.T) {
+	testCases := []struct {
+		primaries   []bool
+		shouldError bool
+	}{
+		{[]bool{true}, false},
+		{[]bool{true, false}, false},
+		{[]bool{false}, true},
+		{[]bool{false, false}, true},
+		{[]bool{true, true}, true},
+	}
+
+	for _, test := range testCases {
+		profiles := make([]interface{}, 0, len(test.primaries))
+		for i, primary := range test.primaries {
+			profiles = append(profiles, map[string]interface{}{
+				"name":    fmt.Sprintf("TestNetworkProfile-%d", i),
+				"primary": primary,
+				"ip_configuration": []interface{}{
+					map[string]interface{}{
+						"name":      "TestIPConfiguration",
+						"subnet_id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Network/virtualNetworks/acctvn/subnets/acctsub",
+					},
+				},
+			})
+		}
+
+		d := resourceArmVirtualMachineScaleSet().TestResourceData()
+		if err := d.Set("network_profile", profiles); err != nil {
+			t.Fatalf("Error setting network_profile: %s", err)
+		}
+
+		_, err := expandAzureRmVirtualMachineScaleSetNetworkProfile(d)
+		if test.shouldError && err == nil {
+			t.Fatalf("Expected expanding network profiles with primaries %v to fail", test.primaries)
+		}
+
+		if !test.shouldError && err != nil {
+			t.Fatalf("Expected expanding network profiles with primaries %v to pass: %s", test.primaries, err)
+		}
+	}
+}
+
+func TestExpandAzureRmVirtualMachineScaleSetNetworkProfile_uniqueNames(t *testing.T) {
+	subnetId := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Network/virtualNetworks/acctvn/subnets/acctsub"
+
+	testCases := []struct {
+		profileNames  []string
+		ipConfigNames []string
+		errorContains string
+	}{
+		{[]string{"TestNetworkProfile-0", "TestNetworkProfile-1"}, []string{"TestIPConfiguration-0", "TestIPConfiguration-1"}, ""},
+		{[]string{"TestNetworkProfile", "TestNetworkProfile"}, []string{"TestIPConfiguration"}, `more than one named "TestNetworkProfile"`},
+		{[]string{"TestNetworkProfile"}, []string{"TestIPConfiguration", "TestIPConfiguration"}, `more than one named "TestIPConfiguration"`},
+	}
+
+	for _, test := range testCases {
+		ipConfigurations := make([]interface{}, 0, len(test.ipConfigNames))
+		for _, name := range test.ipConfigNames {
+			ipConfigurations = append(ipConfigurations, map[string]interface{}{
+				"name":      name,
+				"subnet_id": subnetId,
+			})
+		}
+
+		profiles := make([]interface{}, 0, len(test.profileNames))
+		for i, name := range test.profileNames {
+			profiles = append(profiles, map[string]interface{}{
+				"name":             name,
+				"primary":          i == 0,
+				"ip_configuration": ipConfigurations,
+			})
+		}
+
+		d := resourceArmVirtualMachineScaleSet().TestResourceData()
+		if err := d.Set("network_profile", profiles); err != nil {
+			t.Fatalf("Error setting network_profile: %s", err)
+		}
+
+		_, err := expandAzureRmVirtualMachineScaleSetNetworkProfile(d)
+		if test.errorContains == "" && err != nil {
+			t.Fatalf("Expected expanding network profiles %v with ip configurations %v to pass: %s", test.profileNames, test.ipConfigNames, err)
+		}
+
+		if test.errorContains != "" && (err == nil || !strings.Contains(err.Error(), test.errorContains)) {
+			t.Fatalf("Expected expanding network profiles %v with ip configurations %v to fail with %q, got %v", test.profileNames, test.ipConfigNames, test.errorContains, err)
+		}
+	}
+}
+
+func testVirtualMachineScaleSetClientReturning(statusCode int, body string) *ArmClient {
+	vmssc := compute.NewVirtualMachineScaleSetsClient("00000000-0000-0000-0000-000000000000")
+	vmssc.Sender = autorest.SenderFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: statusCode,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			Request:    r,
+		}, nil
+	})
+
+	return &ArmClient{
+		vmScaleSetClient: vmssc,
+	}
+}
+
+func TestVirtualMachineScaleSetStateRefreshFunc(t *testing.T) {
+	testCases := []struct {
+		statusCode    int
+		body          string
+		expectedState string
+		shouldError   bool
+	}{
+		{http.StatusOK, `{"properties": {"provisioningState": "Succeeded"}}`, "Succeeded", false},
+		{http.StatusOK, `{"properties": {"provisioningState": "Deleting"}}`, "Deleting", false},
+		{http.StatusNotFound, `{"error": {"code": "ResourceNotFound"}}`, "NotFound", false},
+		{http.StatusOK, `{}`, "", true},
+		{http.StatusOK, `{"properties": {}}`, "", true},
+		{http.StatusOK, `{"properties": {"provisioningState": "Failed"}}`, "Failed", true},
+		{http.StatusOK, `{"properties": {"provisioningState": "Canceled"}}`, "Canceled", true},
+	}
+
+	for _, test := range testCases {
+		client := testVirtualMachineScaleSetClientReturning(test.statusCode, test.body)
+
+		_, state, err := virtualMachineScaleSetStateRefreshFunc(client, "acctestrg", "acctvmss", time.Minute, time.Now)()
+		if test.shouldError && err == nil {
+			t.Fatalf("Expected refreshing a %d response with body %s to fail", test.statusCode, test.body)
+		}
+
+		if !test.shouldError && err != nil {
+			t.Fatalf("Expected refreshing a %d response with body %s to pass: %s", test.statusCode, test.body, err)
+		}
+
+		if state != test.expectedState {
+			t.Fatalf("Expected state %q for a %d response with body %s, got %q", test.expectedState, test.statusCode, test.body, state)
+		}
+	}
+}
+
+func TestVirtualMachineScaleSetStateRefreshFunc_failedStopsWaiting(t *testing.T) {
+	client := testVirtualMachineScaleSetClientReturning(http.StatusOK, `{
+		"properties": {"provisioningState": "Failed"},
+		"statuses": [
+			{"code": "ProvisioningState/failed/VMExtensionProvisioningError", "level": "Error", "message": "VM has reported a failure when processing extension 'CustomScript'."}
+		]
+	}`)
+
+	conf := &resource.StateChangeConf{
+		Pending:    []string{"Creating", "Updating"},
+		Target:     []string{"Succeeded"},
+		Refresh:    virtualMachineScaleSetStateRefreshFunc(client, "acctestrg", "acctvmss", time.Minute, time.Now),
+		Timeout:    time.Minute,
+		MinTimeout: 10 * time.Millisecond,
+	}
+
+	start := time.Now()
+	_, err := conf.WaitForState()
+	if err == nil {
+		t.Fatalf("Expected waiting on a failed scale set to return an error")
+	}
+
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("Expected waiting on a failed scale set to stop immediately, took %s", elapsed)
+	}
+
+	if !strings.Contains(err.Error(), "processing extension 'CustomScript'") {
+		t.Fatalf("Expected the error to include the failure detail, got: %s", err)
+	}
+}
+
+func TestVirtualMachineScaleSetStateRefreshFunc_timeoutWarning(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	clock := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+
+	client := testVirtualMachineScaleSetClientReturning(http.StatusOK, `{"properties": {"provisioningState": "Creating"}}`)
+	refresh := virtualMachineScaleSetStateRefreshFunc(client, "acctestrg", "acctvmss", 20*time.Minute, now)
+
+	clock = clock.Add(9 * time.Minute)
+	if _, _, err := refresh(); err != nil {
+		t.Fatalf("Error refreshing: %s", err)
+	}
+	if strings.Contains(logs.String(), "[WARN]") {
+		t.Fatalf("Expected no warning before half of the timeout, got: %s", logs.String())
+	}
+
+	clock = clock.Add(2 * time.Minute)
+	if _, _, err := refresh(); err != nil {
+		t.Fatalf("Error refreshing: %s", err)
+	}
+	if !strings.Contains(logs.String(), `still in provisioning state "Creating" after 11m0s`) {
+		t.Fatalf("Expected a warning with the elapsed time and state after half of the timeout, got: %s", logs.String())
+	}
+
+	warnings := strings.Count(logs.String(), "[WARN]")
+	clock = clock.Add(time.Minute)
+	if _, _, err := refresh(); err != nil {
+		t.Fatalf("Error refreshing: %s", err)
+	}
+	if strings.Count(logs.String(), "[WARN]") != warnings {
+		t.Fatalf("Expected the warning to be logged only once, got: %s", logs.String())
+	}
+}
+
+func TestVirtualMachineScaleSetCreateOrUpdate_skipWait(t *testing.T) {
+	for _, wait := range []bool{true, false} {
+		requests := 0
+		client := compute.NewVirtualMachineScaleSetsClient("00000000-0000-0000-0000-000000000000")
+		client.PollingDelay = time.Millisecond
+		client.Sender = autorest.SenderFunc(func(r *http.Request) (*http.Response, error) {
+			requests++
+
+			// Azure accepts the request and hands back the long-running operation to poll
+			if r.Method == "PUT" {
+				return &http.Response{
+					StatusCode: http.StatusCreated,
+					Header: http.Header{
+						"Content-Type":         []string{"application/json"},
+						"Azure-Asyncoperation": []string{"https://management.azure.com/operations/acctvmss"},
+					},
+					Body:    ioutil.NopCloser(bytes.NewBufferString(`{"properties": {"provisioningState": "Creating"}}`)),
+					Request: r,
+				}, nil
+			}
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"status": "Succeeded"}`)),
+				Request:    r,
+			}, nil
+		})
+
+		name := "acctvmss"
+		if err := virtualMachineScaleSetCreateOrUpdate(client, "acctestrg", name, compute.VirtualMachineScaleSet{Name: &name}, wait, 20*time.Minute); err != nil {
+			t.Fatalf("Error creating the scale set with wait %t: %s", wait, err)
+		}
+
+		if wait && requests < 2 {
+			t.Fatalf("Expected waiting for the scale set to poll the operation, got %d requests", requests)
+		}
+
+		if !wait && requests != 1 {
+			t.Fatalf("Expected skipping the wait to send only the create request, got %d requests", requests)
+		}
+	}
+}
+
+func TestVirtualMachineScaleSetCreateOrUpdate_timeout(t *testing.T) {
+	client := compute.NewVirtualMachineScaleSetsClient("00000000-0000-0000-0000-000000000000")
+	client.PollingDelay = time.Millisecond
+	client.Sender = autorest.SenderFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method == "PUT" {
+			return &http.Response{
+				StatusCode: http.StatusCreated,
+				Header: http.Header{
+					"Content-Type":         []string{"application/json"},
+					"Azure-Asyncoperation": []string{"https://management.azure.com/operations/acctvmss"},
+				},
+				Body:    ioutil.NopCloser(bytes.NewBufferString(`{"properties": {"provisioningState": "Creating"}}`)),
+				Request: r,
+			}, nil
+		}
+
+		// The operation never finishes provisioning
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{"status": "InProgress"}`)),
+			Request:    r,
+		}, nil
+	})
+
+	name := "acctvmss"
+	err := virtualMachineScaleSetCreateOrUpdate(client, "acctestrg", name, compute.VirtualMachineScaleSet{Name: &name}, true, 50*time.Millisecond)
+	if err == nil {
+		t.Fatalf("Expected waiting on an operation which never finishes to time out")
+	}
+
+	if !strings.Contains(err.Error(), "Timed out after 50ms") {
+		t.Fatalf("Expected the error to report the timeout, got %s", err)
+	}
+}
+
+func TestVirtualMachineScaleSetCreateOrUpdate_timeoutWarning(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	client := compute.NewVirtualMachineScaleSetsClient("00000000-0000-0000-0000-000000000000")
+	client.PollingDelay = time.Millisecond
+	client.Sender = autorest.SenderFunc(func(r *http.Request) (*http.Response, error) {
+		body := `{"status": "InProgress"}`
+		switch {
+		case r.Method == "PUT":
+			return &http.Response{
+				StatusCode: http.StatusCreated,
+				Header: http.Header{
+					"Content-Type":         []string{"application/json"},
+					"Azure-Asyncoperation": []string{"https://management.azure.com/operations/acctvmss"},
+				},
+				Body:    ioutil.NopCloser(bytes.NewBufferString(`{"properties": {"provisioningState": "Creating"}}`)),
+				Request: r,
+			}, nil
+		case strings.HasSuffix(r.URL.Path, "/virtualMachineScaleSets/acctvmss"):
+			body = `{"properties": {"provisioningState": "Creating"}}`
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			Request:    r,
+		}, nil
+	})
+
+	name := "acctvmss"
+	if err := virtualMachineScaleSetCreateOrUpdate(client, "acctestrg", name, compute.VirtualMachineScaleSet{Name: &name}, true, 100*time.Millisecond); err == nil {
+		t.Fatalf("Expected waiting on an operation which never finishes to time out")
+	}
+
+	if strings.Count(logs.String(), "[WARN]") != 1 || !strings.Contains(logs.String(), `still in provisioning state "Creating"`) {
+		t.Fatalf("Expected a single warning with the provisioning state once half the timeout elapsed, got: %s", logs.String())
+	}
+}
+
+func TestResourceArmVirtualMachineScaleSetCreate_timeoutKeepsId(t *testing.T) {
+	id := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/acctestrg/providers/Microsoft.Compute/virtualMachineScaleSets/acctvmss"
+	client := compute.NewVirtualMachineScaleSetsClient("00000000-0000-0000-0000-000000000000")
+	client.Sender = autorest.SenderFunc(func(r *http.Request) (*http.Response, error) {
+		body := `{"status": "InProgress"}`
+		switch {
+		case r.Method == "PUT":
+			return &http.Response{
+				StatusCode: http.StatusCreated,
+				Header: http.Header{
+					"Content-Type":         []string{"application/json"},
+					"Azure-Asyncoperation": []string{"https://management.azure.com/operations/acctvmss"},
+				},
+				Body:    ioutil.NopCloser(bytes.NewBufferString(`{"properties": {"provisioningState": "Creating"}}`)),
+				Request: r,
+			}, nil
+		case strings.HasSuffix(r.URL.Path, "/virtualMachineScaleSets/acctvmss"):
+			// The scale set carries on provisioning after the wait is cancelled
+			body = fmt.Sprintf(`{"id": %q, "properties": {"provisioningState": "Creating"}}`, id)
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			Request:    r,
+		}, nil
+	})
+
+	meta := &ArmClient{
+		vmScaleSetClient: client,
+		operationTimeout: 50 * time.Millisecond,
+		pollingInterval:  time.Millisecond,
+	}
+
+	raw := testResourceArmVirtualMachineScaleSetRawConfig("testvm", map[string]interface{}{"environment": "Production"})
+	raw["storage_profile_image_reference"] = []interface{}{
+		map[string]interface{}{
+			"publisher": "Canonical",
+			"offer":     "UbuntuServer",
+			"sku":       "14.04.2-LTS",
+			"version":   "latest",
+		},
+	}
+
+	r := resourceArmVirtualMachineScaleSet()
+	rawConfig, err := config.NewRawConfig(raw)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	diff, err := r.Diff(nil, terraform.NewResourceConfig(rawConfig))
+	if err != nil {
+		t.Fatalf("Error diffing: %s", err)
+	}
+
+	state, err := r.Apply(nil, diff, meta)
+	if err == nil || !strings.Contains(err.Error(), "Timed out after 50ms") {
+		t.Fatalf("Expected creating the scale set to time out, got %v", err)
+	}
+
+	if state == nil || state.ID != id {
+		t.Fatalf("Expected the scale set which timed out to be kept in state with ID %s, got %#v", id, state)
+	}
+}
+
+func TestAccAzureRMVirtualMachineScaleSet_basicLinux(t *testing.T) {
+	ri := acctest.RandInt()
+	config := fmt.Sprintf(testAccAzureRMVirtualMachineScaleSet_basicLinux, ri, ri, ri, ri, ri, ri, ri, ri)
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testCheckAzureRMVirtualMachineScaleSetDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					testCheckAzureRMVirtualMachineScaleSetExists("azurerm_virtual_machine_scale_set.test"),
+					resource.TestCheckResourceAttr("azurerm_virtual_machine_scale_set.test", "provisioning_state", "Succeeded"),
+					resource.TestCheckResourceAttr("azurerm_virtual_machine_scale_set.test", "instances", "2"),
+					resource.TestCheckResourceAttr("azurerm_virtual_machine_scale_set.test", "instance_provisioning_states.succeeded", "2"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccAzureRMVirtualMachineScaleSet_scaleCapacity(t *testing.T) {
+	ri := acctest.RandInt()
+	preConfig := fmt.Sprintf(testAccAzureRMVirtualMachineScaleSet_basicLinux, ri, ri, ri, ri, ri, ri, ri, ri)
+	postConfig := fmt.Sprintf(testAccAzureRMVirtualMachineScaleSet_basicLinuxCapacity, ri, ri, ri, ri, ri, ri, 5, ri, ri)
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testCheckAzureRMVirtualMachineScaleSetDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: preConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testCheckAzureRMVirtualMachineScaleSetExists("azurerm_virtual_machine_scale_set.test"),
+					testCheckAzureRMVirtualMachineScaleSetCapacity("azurerm_virtual_machine_scale_set.test", 2),
+				),
+			},
+
+			{
+				Config: postConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testCheckAzureRMVirtualMachineScaleSetExists("azurerm_virtual_machine_scale_set.test"),
+					testCheckAzureRMVirtualMachineScaleSetCapacity("azurerm_virtual_machine_scale_set.test", 5),
+				),
+			},
+		},
+	})
+}
+
+func TestAccAzureRMVirtualMachineScaleSet_scaleToZero(t *testing.T) {
+	ri := acctest.RandInt()
+	initialConfig := fmt.Sprintf(testAccAzureRMVirtualMachineScaleSet_basicLinuxCapacity, ri, ri, ri, ri, ri, ri, 2, ri, ri)
+	zeroConfig := fmt.Sprintf(testAccAzureRMVirtualMachineScaleSet_basicLinuxCapacity, ri, ri, ri, ri, ri, ri, 0, ri, ri)
+	scaledConfig := fmt.Sprintf(testAccAzureRMVirtualMachineScaleSet_basicLinuxCapacity, ri, ri, ri, ri, ri, ri, 3, ri, ri)
+	var id string
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testCheckAzureRMVirtualMachineScaleSetDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: initialConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testCheckAzureRMVirtualMachineScaleSetExists("azurerm_virtual_machine_scale_set.test"),
+					testCheckAzureRMVirtualMachineScaleSetNotRecreated("azurerm_virtual_machine_scale_set.test", &id),
+					testCheckAzureRMVirtualMachineScaleSetCapacity("azurerm_virtual_machine_scale_set.test", 2),
+				),
+			},
+
+			{
+				Config: zeroConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testCheckAzureRMVirtualMachineScaleSetExists("azurerm_virtual_machine_scale_set.test"),
+					testCheckAzureRMVirtualMachineScaleSetNotRecreated("azurerm_virtual_machine_scale_set.test", &id),
+					testCheckAzureRMVirtualMachineScaleSetCapacity("azurerm_virtual_machine_scale_set.test", 0),
+					resource.TestCheckResourceAttr("azurerm_virtual_machine_scale_set.test", "instances", "0"),
+				),
+			},
+
+			{
+				Config: scaledConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testCheckAzureRMVirtualMachineScaleSetExists("azurerm_virtual_machine_scale_set.test"),
+					testCheckAzureRMVirtualMachineScaleSetNotRecreated("azurerm_virtual_machine_scale_set.test", &id),
+					testCheckAzureRMVirtualMachineScaleSetCapacity("azurerm_virtual_machine_scale_set.test", 3),
+				),
+			},
+		},
+	})
+}
+
+func TestAccAzureRMVirtualMachineScaleSet_capacityDrift(t *testing.T) {
+	ri := acctest.RandInt()
+	config := fmt.Sprintf(testAccAzureRMVirtualMachineScaleSet_basicLinux, ri, ri, ri, ri, ri, ri, ri, ri)
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testCheckAzureRMVirtualMachineScaleSetDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					testCheckAzureRMVirtualMachineScaleSetExists("azurerm_virtual_machine_scale_set.test"),
+					testCheckAzureRMVirtualMachineScaleSetCapacity("azurerm_virtual_machine_scale_set.test", 2),
+					testCheckAzureRMVirtualMachineScaleSetScaleOutOfBand("azurerm_virtual_machine_scale_set.test", 3),
+				),
+				ExpectNonEmptyPlan: true,
+			},
+
+			{
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					testCheckAzureRMVirtualMachineScaleSetExists("azurerm_virtual_machine_scale_set.test"),
+					testCheckAzureRMVirtualMachineScaleSetCapacity("azurerm_virtual_machine_scale_set.test", 2),
+				),
+			},
+		},
+	})
+}
+
+func TestAccAzureRMVirtualMachineScaleSet_deleteReleasesSubnet(t *testing.T) {
+	ri := acctest.RandInt()
+	preConfig := fmt.Sprintf(testAccAzureRMVirtualMachineScaleSet_basicLinux, ri, ri, ri, ri, ri, ri, ri, ri)
+	postConfig := fmt.Sprintf(testAccAzureRMVirtualMachineScaleSet_basicLinuxNetworkOnly, ri, ri, ri, ri, ri)
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testCheckAzureRMSubnetDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: preConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testCheckAzureRMVirtualMachineScaleSetExists("azurerm_virtual_machine_scale_set.test"),
+				),
+			},
+
+			{
+				Config: postConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testCheckAzureRMVirtualMachineScaleSetDestroy,
+					testCheckAzureRMSubnetExists("azurerm_subnet.test"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccAzureRMVirtualMachineScaleSet_extension(t *testing.T) {
+	ri := acctest.RandInt()
+	config := fmt.Sprintf(testAccAzureRMVirtualMachineScaleSet_extension, ri, ri, ri, ri, ri, ri, ri, ri)
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testCheckAzureRMVirtualMachineScaleSetDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					testCheckAzureRMVirtualMachineScaleSetExists("azurerm_virtual_machine_scale_set.test"),
+					testCheckAzureRMVirtualMachineScaleSetExtension("azurerm_virtual_machine_scale_set.test", "CustomScript"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccAzureRMVirtualMachineScaleSet_extensionProtectedSettings(t *testing.T) {
+	ri := acctest.RandInt()
+	config := fmt.Sprintf(testAccAzureRMVirtualMachineScaleSet_extensionProtectedSettings, ri, ri, ri, ri, ri, ri, ri, ri)
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testCheckAzureRMVirtualMachineScaleSetDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					testCheckAzureRMVirtualMachineScaleSetExists("azurerm_virtual_machine_scale_set.test"),
+					testCheckAzureRMVirtualMachineScaleSetExtension("azurerm_virtual_machine_scale_set.test", "CustomScript"),
+				),
+			},
+
+			{
+				// Applying the same protected settings again must not produce a diff
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					testCheckAzureRMVirtualMachineScaleSetExists("azurerm_virtual_machine_scale_set.test"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccAzureRMVirtualMachineScaleSet_overprovision(t *testing.T) {
+	ri := acctest.RandInt()
+	config := fmt.Sprintf(testAccAzureRMVirtualMachineScaleSet_overprovision, ri, ri, ri, ri, ri, ri, ri, ri)
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testCheckAzureRMVirtualMachineScaleSetDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					testCheckAzureRMVirtualMachineScaleSetExists("azurerm_virtual_machine_scale_set.test"),
+					testCheckAzureRMVirtualMachineScaleSetOverprovision("azurerm_virtual_machine_scale_set.test", false),
+					resource.TestCheckResourceAttr("azurerm_virtual_machine_scale_set.test", "overprovision", "false"),
+				),
+			},
+		},
+	})
+}
+
+func TestWaitForVirtualMachineScaleSetDeletion_neverCompletes(t *testing.T) {
+	d := resourceArmVirtualMachineScaleSet().TestResourceData()
+	client := testVirtualMachineScaleSetClientReturning(http.StatusOK, `{"properties": {"provisioningState": "Deleting"}}`)
+
+	conf := virtualMachineScaleSetDeleteStateChangeConf(d, client, "acctestrg", "acctvmss")
+	conf.Timeout = 50 * time.Millisecond
+	conf.MinTimeout = 10 * time.Millisecond
+
+	err := waitForVirtualMachineScaleSetDeletion(conf, client, "acctestrg", "acctvmss")
+	if err == nil {
+		t.Fatalf("Expected waiting for a scale set which is never deleted to fail")
+	}
+
+	for _, expected := range []string{"timeout", `provisioning state "Deleting"`} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected the error to contain %q, got: %s", expected, err)
+		}
+	}
+}
+
+func TestValidateArmVirtualMachineScaleSetVhdContainer(t *testing.T) {
+	testCases := []struct {
+		input         string
+		shouldWarning bool
+		shouldError   bool
+	}{
+		{"https://acctestsa.blob.core.windows.net/vhds", false, false},
+		{"https://acctestsa.blob.core.windows.net/vhds/", false, false},
+		{"https://acctestsa.blob.core.chinacloudapi.cn/vhds", false, false},
+		{"http://acctestsa.blob.core.windows.net/vhds", true, false},
+		{"acctestsa.blob.core.windows.net/vhds", false, true},
+		{"ftp://acctestsa.blob.core.windows.net/vhds", false, true},
+		{"https://acctestsa.file.core.windows.net/vhds", false, true},
+		{"https://blob.core.windows.net/vhds", false, true},
+		{"https://acctestsa.blob.core.windows.net", false, true},
+		{"https://acctestsa.blob.core.windows.net/vhds/disk.vhd", false, true},
+		{"", false, true},
+	}
+
+	for _, test := range testCases {
+		ws, es := validateArmVirtualMachineScaleSetVhdContainer(test.input, "vhd_containers")
+
+		if test.shouldError && len(es) == 0 {
+			t.Fatalf("Expected validating vhd_containers %q to fail", test.input)
+		}
+
+		if !test.shouldError && len(es) > 0 {
+			t.Fatalf("Expected validating vhd_containers %q to pass: %v", test.input, es)
+		}
+
+		if test.shouldWarning != (len(ws) > 0) {
+			t.Fatalf("Expected validating vhd_containers %q to warn to be %t, got %v", test.input, test.shouldWarning, ws)
+		}
+	}
+}
+
+func TestValidateArmVirtualMachineScaleSetCustomData(t *testing.T) {
+	testCases := []struct {
+		input       string
+		shouldError bool
+	}{
+		{base64.StdEncoding.EncodeToString([]byte("#!/bin/bash\necho hello")), false},
+		{base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("a"), 65535)), false},
+		{base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("a"), 65536)), true},
+		{"IyEvYmluL2Jhc2gK\n ZWNobyBoZWxsbw==", false},
+		{"IyEvYmluL2Jhc2gKZWNobyBoZWxsbw", false},
+		{"#!/bin/bash", true},
+	}
+
+	for _, test := range testCases {
+		_, es := validateArmVirtualMachineScaleSetCustomData(test.input, "custom_data")
+
+		if test.shouldError && len(es) == 0 {
+			t.Fatalf("Expected validating custom_data of length %d to fail", len(test.input))
+		}
+
+		if !test.shouldError && len(es) > 0 {
+			t.Fatalf("Expected validating custom_data of length %d to pass: %v", len(test.input), es)
+		}
+	}
+}
+
+func TestNormalizeArmVirtualMachineScaleSetCustomData(t *testing.T) {
+	canonical := base64.StdEncoding.EncodeToString([]byte("#!/bin/bash\necho hello"))
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{canonical, canonical},
+		{"IyEvYmluL2Jh\nc2gKZWNobyBoZWxsbw==\n", canonical},
+		{"  IyEvYmluL2Jhc2gKZWNobyBoZWxsbw== ", canonical},
+		{"IyEvYmluL2Jhc2gKZWNobyBoZWxsbw", canonical},
+		{"#!/bin/bash", "#!/bin/bash"},
+	}
+
+	for _, test := range testCases {
+		if actual := normalizeArmVirtualMachineScaleSetCustomData(test.input); actual != test.expected {
+			t.Fatalf("Expected custom_data %q to normalize to %q, got %q", test.input, test.expected, actual)
+		}
+	}
+}
+
+func TestResourceArmVirtualMachineScaleSet_customDataEncodingNoDiff(t *testing.T) {
+	testCases := []struct {
+		customData string
+		expectDiff bool
+	}{
+		{"IyEvYmluL2Jhc2gKZWNobyBoZWxsbw==", false},
+		{"IyEvYmluL2Jh\nc2gKZWNobyBoZWxsbw==\n", false},
+		{"IyEvYmluL2Jhc2gKZWNobyBoZWxsbw", false},
+		{base64.StdEncoding.EncodeToString([]byte("#!/bin/bash\necho goodbye")), true},
+	}
+
+	for _, test := range testCases {
+		before := testResourceArmVirtualMachineScaleSetRawConfig("testvm", map[string]interface{}{"environment": "Production"})
+		before["os_profile"].([]interface{})[0].(map[string]interface{})["custom_data"] = "IyEvYmluL2Jhc2gKZWNobyBoZWxsbw=="
+
+		after := testResourceArmVirtualMachineScaleSetRawConfig("testvm", map[string]interface{}{"environment": "Production"})
+		after["os_profile"].([]interface{})[0].(map[string]interface{})["custom_data"] = test.customData
+
+		_, diff := testResourceArmVirtualMachineScaleSetDiff(t, before, after)
+
+		hasDiff := false
+		if diff != nil {
+			for k := range diff.Attributes {
+				if strings.HasPrefix(k, "os_profile.") {
+					hasDiff = true
+				}
+			}
+		}
+
+		if hasDiff != test.expectDiff {
+			t.Fatalf("Expected a diff for custom_data %q to be %t, got %#v", test.customData, test.expectDiff, diff)
+		}
+	}
+}
+
+func TestExpandAzureRMVirtualMachineScaleSetsStorageProfileOsDisk_imageSource(t *testing.T) {
+	testCases := []struct {
+		createOption      string
+		image             string
+		hasImageReference bool
+		shouldError       bool
+	}{
+		{"FromImage", "", true, false},
+		{"FromImage", "https://acctestsa.blob.core.windows.net/images/image.vhd", false, false},
+		{"FromImage", "", false, true},
+		{"FromImage", "https://acctestsa.blob.core.windows.net/images/image.vhd", true, true},
+		{"Empty", "", false, false},
+		{"Empty", "", true, true},
+		{"Empty", "https://acctestsa.blob.core.windows.net/images/image.vhd", false, true},
+	}
+
+	for _, test := range testCases {
+		d := resourceArmVirtualMachineScaleSet().TestResourceData()
+		d.Set("storage_profile_os_disk", []interface{}{
+			map[string]interface{}{
+				"name":           "osDiskProfile",
+				"image":          test.image,
+				"caching":        "ReadWrite",
+				"os_type":        "Linux",
+				"create_option":  test.createOption,
+				"vhd_containers": schema.NewSet(schema.HashString, []interface{}{"https://acctestsa.blob.core.windows.net/vhds"}),
+			},
+		})
+
+		if test.hasImageReference {
+			d.Set("storage_profile_image_reference", []interface{}{
+				map[string]interface{}{
+					"publisher": "Canonical",
+					"offer":     "UbuntuServer",
+					"sku":       "14.04.2-LTS",
+					"version":   "latest",
+				},
+			})
+		}
+
+		_, err := expandAzureRMVirtualMachineScaleSetsStorageProfileOsDisk(d)
+		if test.shouldError && err == nil {
+			t.Fatalf("Expected expanding create_option %q with image %q and image reference %t to fail", test.createOption, test.image, test.hasImageReference)
+		}
+
+		if !test.shouldError && err != nil {
+			t.Fatalf("Expected expanding create_option %q with image %q and image reference %t to pass: %s", test.createOption, test.image, test.hasImageReference, err)
+		}
+	}
+}
+
+func TestValidateArmVirtualMachineScaleSetOsType(t *testing.T) {
+	testCases := []struct {
+		input       string
+		shouldError bool
+	}{
+		{"Linux", false},
+		{"Windows", false},
+		{"linux", true},
+		{"windows", true},
+		{"Ubuntu", true},
+		{"", true},
+	}
+
+	for _, test := range testCases {
+		_, es := validateArmVirtualMachineScaleSetOsType(test.input, "os_type")
+
+		if test.shouldError && len(es) == 0 {
+			t.Fatalf("Expected validating os_type %q to fail", test.input)
+		}
+
+		if !test.shouldError && len(es) > 0 {
+			t.Fatalf("Expected validating os_type %q to pass: %v", test.input, es)
+		}
+	}
+}
+
+func TestExpandAzureRMVirtualMachineScaleSetsStorageProfileOsDisk_osType(t *testing.T) {
+	testCases := []struct {
+		osType      string
+		osConfig    string
+		shouldError bool
+	}{
+		{"Linux", "os_profile_linux_config", false},
+		{"Linux", "os_profile_windows_config", true},
+		{"Windows", "os_profile_windows_config", false},
+		{"Windows", "os_profile_linux_config", true},
+		{"", "os_profile_linux_config", false},
+		{"", "os_profile_windows_config", false},
+	}
+
+	for _, test := range testCases {
+		d := resourceArmVirtualMachineScaleSet().TestResourceData()
+		d.Set("storage_profile_os_disk", []interface{}{
+			map[string]interface{}{
+				"name":           "osDiskProfile",
+				"caching":        "ReadWrite",
+				"os_type":        test.osType,
+				"create_option":  "Empty",
+				"vhd_containers": schema.NewSet(schema.HashString, []interface{}{"https://acctestsa.blob.core.windows.net/vhds"}),
+			},
+		})
+
+		if test.osConfig == "os_profile_windows_config" {
+			d.Set("os_profile_windows_config", []interface{}{
+				map[string]interface{}{
+					"provision_vm_agent": true,
+				},
+			})
+		} else {
+			d.Set("os_profile_linux_config", []interface{}{
+				map[string]interface{}{
+					"disable_password_authentication": true,
+				},
+			})
+		}
+
+		_, err := expandAzureRMVirtualMachineScaleSetsStorageProfileOsDisk(d)
+		if test.shouldError && err == nil {
+			t.Fatalf("Expected expanding os_type %q with %s to fail", test.osType, test.osConfig)
+		}
+
+		if !test.shouldError && err != nil {
+			t.Fatalf("Expected expanding os_type %q with %s to pass: %s", test.osType, test.osConfig, err)
+		}
+	}
+}
+
+func TestValidateArmVirtualMachineScaleSetPollingInterval(t *testing.T) {
+	testCases := []struct {
+		input       string
+		shouldError bool
+	}{
+		{"1s", false},
+		{"30s", false},
+		{"2m", false},
+		{"500ms", true},
+		{"10", true},
+		{"ten seconds", true},
+		{"", true},
+	}
+
+	for _, test := range testCases {
+		_, es := validateArmVirtualMachineScaleSetPollingInterval(test.input, "polling_interval")
+
+		if test.shouldError && len(es) == 0 {
+			t.Fatalf("Expected validating polling_interval %q to fail", test.input)
+		}
+
+		if !test.shouldError && len(es) > 0 {
+			t.Fatalf("Expected validating polling_interval %q to pass: %v", test.input, es)
+		}
+	}
+}
+
+func TestVirtualMachineScaleSetDeleteStateChangeConf_pollingInterval(t *testing.T) {
+	d := resourceArmVirtualMachineScaleSet().TestResourceData()
+	client := testVirtualMachineScaleSetClientReturning(http.StatusNotFound, `{}`)
+
+	conf := virtualMachineScaleSetDeleteStateChangeConf(d, client, "acctestrg", "acctvmss")
+	if conf.MinTimeout != 10*time.Second {
+		t.Fatalf("Expected the default polling interval of 10s, got %s", conf.MinTimeout)
+	}
+
+	d.Set("polling_interval", "1s")
+
+	// Azure reports the scale set as deleting a few times before it is gone
+	responses := []struct {
+		statusCode int
+		body       string
+	}{
+		{http.StatusOK, `{"properties": {"provisioningState": "Deleting"}}`},
+		{http.StatusOK, `{"properties": {"provisioningState": "Deleting"}}`},
+		{http.StatusOK, `{"properties": {"provisioningState": "Deleting"}}`},
+		{http.StatusOK, `{"properties": {"provisioningState": "Deleting"}}`},
+		{http.StatusOK, `{"properties": {"provisioningState": "Deleting"}}`},
+		{http.StatusNotFound, `{}`},
+	}
+	clock := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	calls := make([]time.Time, 0, len(responses))
+	client.vmScaleSetClient.Sender = autorest.SenderFunc(func(r *http.Request) (*http.Response, error) {
+		response := responses[len(calls)]
+		calls = append(calls, clock)
+		return &http.Response{
+			StatusCode: response.statusCode,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       ioutil.NopCloser(bytes.NewBufferString(response.body)),
+			Request:    r,
+		}, nil
+	})
+
+	conf = virtualMachineScaleSetDeleteStateChangeConf(d, client, "acctestrg", "acctvmss")
+	if conf.MinTimeout != time.Second {
+		t.Fatalf("Expected a polling interval of 1s, got %s", conf.MinTimeout)
+	}
+
+	start := clock
+	now := func() time.Time { return clock }
+	sleep := func(wait time.Duration) { clock = clock.Add(wait) }
+	if _, err := virtualMachineScaleSetWaitForState(conf, now, sleep); err != nil {
+		t.Fatalf("Error waiting for the scale set to be deleted: %s", err)
+	}
+
+	if len(calls) != len(responses) {
+		t.Fatalf("Expected %d refreshes, got %d", len(responses), len(calls))
+	}
+
+	// The interval holds until the backoff grows beyond it
+	expected := []time.Duration{time.Second, time.Second, time.Second, time.Second, 1600 * time.Millisecond, 3200 * time.Millisecond}
+	previous := start
+	for i, call := range calls {
+		if call.Sub(previous) != expected[i] {
+			t.Fatalf("Expected refresh %d to wait %s, waited %s", i, expected[i], call.Sub(previous))
+		}
+		previous = call
+	}
+}
+
+func TestVirtualMachineScaleSetDeleteStateChangeConf_providerDefaults(t *testing.T) {
+	d := resourceArmVirtualMachineScaleSet().TestResourceData()
+	client := testVirtualMachineScaleSetClientReturning(http.StatusNotFound, `{}`)
+
+	conf := virtualMachineScaleSetDeleteStateChangeConf(d, client, "acctestrg", "acctvmss")
+	if conf.Timeout != 20*time.Minute {
+		t.Fatalf("Expected the default timeout of 20m, got %s", conf.Timeout)
+	}
+
+	// The provider block sets the defaults for every long-running operation
+	client.operationTimeout = 45 * time.Minute
+	client.pollingInterval = 30 * time.Second
+
+	conf = virtualMachineScaleSetDeleteStateChangeConf(d, client, "acctestrg", "acctvmss")
+	if conf.Timeout != 45*time.Minute {
+		t.Fatalf("Expected the provider timeout of 45m, got %s", conf.Timeout)
+	}
+	if conf.MinTimeout != 30*time.Second {
+		t.Fatalf("Expected the provider polling interval of 30s, got %s", conf.MinTimeout)
+	}
+
+	// polling_interval on the resource takes precedence over the provider
+	d.Set("polling_interval", "1m")
+
+	conf = virtualMachineScaleSetDeleteStateChangeConf(d, client, "acctestrg", "acctvmss")
+	if conf.MinTimeout != time.Minute {
+		t.Fatalf("Expected the resource polling interval of 1m, got %s", conf.MinTimeout)
+	}
+}
+
+func TestExpandAzureRmVirtualMachineScaleSetCapacityUpdate(t *testing.T) {
+	d := resourceArmVirtualMachineScaleSet().TestResourceData()
+	d.Set("name", "acctvmss")
+	d.Set("location", "westus")
+	d.Set("upgrade_policy_mode", "Manual")
+	d.Set("sku", []interface{}{
+		map[string]interface{}{
+			"name":     "Standard_A0",
+			"tier":     "Standard",
+			"capacity": 5,
+		},
+	})
+
+	scaleSet, err := expandAzureRmVirtualMachineScaleSetCapacityUpdate(d, testVirtualMachineScaleSetExisting())
+	if err != nil {
+		t.Fatalf("Error expanding capacity update: %s", err)
+	}
+
+	if scaleSet.Sku == nil || scaleSet.Sku.Capacity == nil || *scaleSet.Sku.Capacity != 5 {
+		t.Fatalf("Expected the sku capacity to be 5, got %#v", scaleSet.Sku)
+	}
+
+	testCheckVirtualMachineScaleSetUpdateKeepsExisting(t, scaleSet)
+}
+
+func TestResourceArmVirtualMachineScaleSet_capacityOnlyUpdate(t *testing.T) {
+	testCases := []struct {
+		afterTags            map[string]interface{}
+		expectedCapacityOnly bool
+	}{
+		{map[string]interface{}{"environment": "Production"}, true},
+		{map[string]interface{}{"environment": "Staging"}, false},
+	}
+
+	for _, test := range testCases {
+		before := testResourceArmVirtualMachineScaleSetRawConfigWithExtension(map[string]interface{}{"environment": "Production"}, `{"commandToExecute": "echo one"}`)
+		before["extension"].([]interface{})[0].(map[string]interface{})["protected_settings"] = `{"storageAccountKey": "secret"}`
+		after := testResourceArmVirtualMachineScaleSetRawConfigWithExtension(test.afterTags, `{"commandToExecute": "echo one"}`)
+		after["extension"].([]interface{})[0].(map[string]interface{})["protected_settings"] = `{"storageAccountKey": "secret"}`
+		after["sku"].([]interface{})[0].(map[string]interface{})["capacity"] = 5
+		state, diff := testResourceArmVirtualMachineScaleSetDiff(t, before, after)
+
+		var capacityOnly bool
+		var params *compute.VirtualMachineScaleSet
+		r := resourceArmVirtualMachineScaleSet()
+		r.Update = func(d *schema.ResourceData, meta interface{}) error {
+			capacityOnly = resourceArmVirtualMachineScaleSetOnlyCapacityChanged(d)
+
+			// Azure returns the extensions without their protected settings
+			existing := testVirtualMachineScaleSetExisting()
+			extensionName := "CustomScript"
+			existing.Properties.VirtualMachineProfile.ExtensionProfile = &compute.VirtualMachineScaleSetExtensionProfile{
+				Extensions: &[]compute.VirtualMachineScaleSetExtension{
+					{Name: &extensionName},
+				},
+			}
+
+			var err error
+			params, err = expandAzureRmVirtualMachineScaleSetCapacityUpdate(d, existing)
+			return err
+		}
+
+		if _, err := r.Apply(state, diff, nil); err != nil {
+			t.Fatalf("Error applying the updated configuration: %s", err)
+		}
+
+		if capacityOnly != test.expectedCapacityOnly {
+			t.Fatalf("Expected only the capacity to have changed to be %t for tags %v, got %t", test.expectedCapacityOnly, test.afterTags, capacityOnly)
+		}
+
+		if *params.Sku.Capacity != 5 {
+			t.Fatalf("Expected the updated capacity of 5, got %d", *params.Sku.Capacity)
+		}
+
+		extensions := *params.Properties.VirtualMachineProfile.ExtensionProfile.Extensions
+		if len(extensions) != 1 {
+			t.Fatalf("Expected 1 extension, got %d", len(extensions))
+		}
+
+		if extensions[0].Properties == nil || extensions[0].Properties.ProtectedSettings == nil {
+			t.Fatalf("Expected the protected settings to be sent from config, got %#v", extensions[0])
+		}
+
+		protectedSettings := *extensions[0].Properties.ProtectedSettings
+		if protectedSettings["storageAccountKey"] != "secret" {
+			t.Fatalf("Expected the configured protected settings, got %v", protectedSettings)
+		}
+	}
+}
+
+//func TestAccAzureRMVirtualMachineScaleSet_basicWindowsMachine(t *testing.T) {
+//	ri := acctest.RandInt()
+//	rs := acctest.RandString(6)
+//	config := fmt.Sprintf(testAccAzureRMVirtualMachineScaleSet_basicWindows, ri, ri, ri, ri, ri, ri, rs, ri)
+//	resource.Test(t, resource.TestCase{
+//		PreCheck:     func() { testAccPreCheck(t) },
+//		Providers:    testAccProviders,
+//		CheckDestroy: testCheckAzureRMVirtualMachineScaleSetDestroy,
+//		Steps: []resource.TestStep{
+//			{
+//				Config: config,
+//				Check: resource.ComposeTestCheckFunc(
+//					testCheckAzureRMVirtualMachineScaleSetExists("azurerm_virtual_machine_scale_set.test"),
+//				),
+//			},
+//		},
+//	})
+//}
+
+func testCheckAzureRMVirtualMachineScaleSetExists(name string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		// Ensure we have enough information in state to look up in API
+		rs, ok := s.RootModule().Resources[name]
+		if !ok {
+			return fmt.Errorf("Not found: %s", name)
+		}
+
+		name := rs.Primary.Attributes["name"]
+		resourceGroup, hasResourceGroup := rs.Primary.Attributes["resource_group_name"]
+		if !hasResourceGroup {
+			return fmt.Errorf("Bad: no resource group found in state for virtual machine: scale set %s", name)
+		}
+
+		conn := testAccProvider.Meta().(*ArmClient).vmScaleSetClient
+
+		resp, err := conn.Get(resourceGroup, name)
+		if err != nil {
+			return fmt.Errorf("Bad: Get on vmScaleSetClient: %s", err)
+		}
+
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("Bad: VirtualMachineScaleSet %q (resource group: %q) does not exist", name, resourceGroup)
+		}
+
+		return nil
+	}
+}
+
+func testCheckAzureRMVirtualMachineScaleSetCapacity(name string, capacity int64) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[name]
+		if !ok {
+			return fmt.Errorf("Not found: %s", name)
+		}
+
+		name := rs.Primary.Attributes["name"]
+		resourceGroup := rs.Primary.Attributes["resource_group_name"]
+
+		conn := testAccProvider.Meta().(*ArmClient).vmScaleSetClient
+
+		resp, err := conn.Get(resourceGroup, name)
+		if err != nil {
+			return fmt.Errorf("Bad: Get on vmScaleSetClient: %s", err)
+		}
+
+		if resp.Sku == nil {
+			return fmt.Errorf("Bad: VirtualMachineScaleSet %q (resource group: %q) has no sku", name, resourceGroup)
+		}
+
+		actual := int64(0)
+		if resp.Sku.Capacity != nil {
+			actual = *resp.Sku.Capacity
+		}
+
+		if actual != capacity {
+			return fmt.Errorf("Bad: VirtualMachineScaleSet %q (resource group: %q) has capacity %d, expected %d", name, resourceGroup, actual, capacity)
+		}
+
+		return nil
+	}
+}
+
+// testCheckAzureRMVirtualMachineScaleSetNotRecreated records the ID of a scale
+// set the first time it is called, and fails if a later step has a different
+// ID, meaning the scale set has been recreated.
+func testCheckAzureRMVirtualMachineScaleSetNotRecreated(name string, id *string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[name]
+		if !ok {
+			return fmt.Errorf("Not found: %s", name)
+		}
+
+		if *id == "" {
+			*id = rs.Primary.ID
+			return nil
+		}
+
+		if rs.Primary.ID != *id {
+			return fmt.Errorf("Bad: VirtualMachineScaleSet %q was recreated: ID changed from %q to %q", name, *id, rs.Primary.ID)
+		}
+
+		return nil
+	}
+}
+
+// testCheckAzureRMVirtualMachineScaleSetScaleOutOfBand changes the capacity of
+// a scale set behind Terraform's back, as autoscale or an operator would.
+func testCheckAzureRMVirtualMachineScaleSetScaleOutOfBand(name string, capacity int64) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[name]
+		if !ok {
+			return fmt.Errorf("Not found: %s", name)
+		}
+
+		name := rs.Primary.Attributes["name"]
+		resourceGroup := rs.Primary.Attributes["resource_group_name"]
+
+		conn := testAccProvider.Meta().(*ArmClient).vmScaleSetClient
+
+		resp, err := conn.Get(resourceGroup, name)
+		if err != nil {
+			return fmt.Errorf("Bad: Get on vmScaleSetClient: %s", err)
+		}
+
+		scaleSet := compute.VirtualMachineScaleSet{
+			Name:     resp.Name,
+			Location: resp.Location,
+			Sku: &compute.Sku{
+				Name:     resp.Sku.Name,
+				Tier:     resp.Sku.Tier,
+				Capacity: &capacity,
+			},
+		}
+
+		if _, err := conn.CreateOrUpdate(resourceGroup, name, scaleSet, make(chan struct{})); err != nil {
+			return fmt.Errorf("Bad: CreateOrUpdate on vmScaleSetClient: %s", err)
+		}
+
+		return nil
+	}
+}
+
+func testCheckAzureRMVirtualMachineScaleSetOverprovision(name string, overprovision bool) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[name]
+		if !ok {
+			return fmt.Errorf("Not found: %s", name)
+		}
+
+		name := rs.Primary.Attributes["name"]
+		resourceGroup := rs.Primary.Attributes["resource_group_name"]
+
+		conn := testAccProvider.Meta().(*ArmClient).vmScaleSetClient
+
+		resp, err := conn.Get(resourceGroup, name)
+		if err != nil {
+			return fmt.Errorf("Bad: Get on vmScaleSetClient: %s", err)
+		}
+
+		if resp.Properties.OverProvision == nil || *resp.Properties.OverProvision != overprovision {
+			return fmt.Errorf("Bad: VirtualMachineScaleSet %q (resource group: %q) expected overprovision to be %t", name, resourceGroup, overprovision)
+		}
+
+		return nil
+	}
+}
+
+func testCheckAzureRMVirtualMachineScaleSetExtension(name string, extensionName string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[name]
+		if !ok {
+			return fmt.Errorf("Not found: %s", name)
+		}
+
+		name := rs.Primary.Attributes["name"]
+		resourceGroup := rs.Primary.Attributes["resource_group_name"]
+
+		conn := testAccProvider.Meta().(*ArmClient).vmScaleSetClient
+
+		resp, err := conn.Get(resourceGroup, name)
+		if err != nil {
+			return fmt.Errorf("Bad: Get on vmScaleSetClient: %s", err)
+		}
+
+		profile := resp.Properties.VirtualMachineProfile.ExtensionProfile
+		if profile == nil || profile.Extensions == nil {
+			return fmt.Errorf("Bad: VirtualMachineScaleSet %q (resource group: %q) has no extensions", name, resourceGroup)
+		}
+
+		for _, extension := range *profile.Extensions {
+			if extension.Name != nil && *extension.Name == extensionName {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("Bad: VirtualMachineScaleSet %q (resource group: %q) has no extension named %q", name, resourceGroup, extensionName)
+	}
+}
+
+func testCheckAzureRMVirtualMachineScaleSetDestroy(s *terraform.State) error {
+	conn := testAccProvider.Meta().(*ArmClient).vmScaleSetClient
+
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "azurerm_virtual_machine_scale_set" {
+			continue
+		}
+
+		name := rs.Primary.Attributes["name"]
+		resourceGroup := rs.Primary.Attributes["resource_group_name"]
+
+		resp, err := conn.Get(resourceGroup, name)
+
+		if err != nil {
+			return nil
+		}
+
+		if resp.StatusCode != http.StatusNotFound {
+			return fmt.Errorf("Virtual Machine Scale Set still exists:\n%#v", resp.Properties)
+		}
+	}
+
+	return nil
+}
+
+var testAccAzureRMVirtualMachineScaleSet_basicLinux = `
+resource "azurerm_resource_group" "test" {
+    name = "acctestrg-%d"
+    location = "West US"
+}
+
+resource "azurerm_virtual_network" "test" {
+    name = "acctvn-%d"
+    address_space = ["10.0.0.0/16"]
+    location = "West US"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+}
+
+resource "azurerm_subnet" "test" {
+    name = "acctsub-%d"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+    virtual_network_name = "${azurerm_virtual_network.test.name}"
+    address_prefix = "10.0.2.0/24"
+}
+
+resource "azurerm_network_interface" "test" {
+    name = "acctni-%d"
+    location = "West US"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+
+    ip_configuration {
+    	name = "testconfiguration1"
+    	subnet_id = "${azurerm_subnet.test.id}"
+    	private_ip_address_allocation = "dynamic"
+    }
+}
+
+resource "azurerm_storage_account" "test" {
+    name = "accsa%d"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+    location = "westus"
+    account_type = "Standard_LRS"
+
+    tags {
+        environment = "staging"
+    }
+}
+
+resource "azurerm_storage_container" "test" {
+    name = "vhds"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+    storage_account_name = "${azurerm_storage_account.test.name}"
+    container_access_type = "private"
+}
+
+resource "azurerm_virtual_machine_scale_set" "test" {
+  name = "acctvmss-%d"
+  location = "West US"
+  resource_group_name = "${azurerm_resource_group.test.name}"
+  upgrade_policy_mode = "Manual"
+
+  sku {
+    name = "Standard_A0"
+    tier = "Standard"
+    capacity = 2
+  }
+
+  os_profile {
+    computer_name_prefix = "testvm-%d"
+    admin_username = "myadmin"
+    admin_password = "Passwword1234"
+  }
+
+  network_profile {
+      name = "TestNetworkProfile-%d"
+      primary = true
+      ip_configuration {
+        name = "TestIPConfiguration"
+        subnet_id = "${azurerm_subnet.test.id}"
+      }
+  }
+
+  storage_profile_os_disk {
+    name = "osDiskProfile"
+    caching       = "ReadWrite"
+    create_option = "FromImage"
+    vhd_containers = ["${azurerm_storage_account.test.primary_blob_endpoint}${azurerm_storage_container.test.name}"]
+  }
+
+  storage_profile_image_reference {
+    publisher = "Canonical"
+    offer     = "UbuntuServer"
+    sku       = "14.04.2-LTS"
+    version   = "latest"
+  }
+}
+`
+
+var testAccAzureRMVirtualMachineScaleSet_basicLinuxCapacity = `
+resource "azurerm_resource_group" "test" {
+    name = "acctestrg-%d"
+    location = "West US"
+}
+
+resource "azurerm_virtual_network" "test" {
+    name = "acctvn-%d"
+    address_space = ["10.0.0.0/16"]
+    location = "West US"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+}
+
+resource "azurerm_subnet" "test" {
+    name = "acctsub-%d"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+    virtual_network_name = "${azurerm_virtual_network.test.name}"
+    address_prefix = "10.0.2.0/24"
+}
+
+resource "azurerm_network_interface" "test" {
+    name = "acctni-%d"
+    location = "West US"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+
+    ip_configuration {
+    	name = "testconfiguration1"
+    	subnet_id = "${azurerm_subnet.test.id}"
+    	private_ip_address_allocation = "dynamic"
+    }
+}
+
+resource "azurerm_storage_account" "test" {
+    name = "accsa%d"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+    location = "westus"
+    account_type = "Standard_LRS"
+
+    tags {
+        environment = "staging"
+    }
+}
+
+resource "azurerm_storage_container" "test" {
+    name = "vhds"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+    storage_account_name = "${azurerm_storage_account.test.name}"
+    container_access_type = "private"
+}
+
+resource "azurerm_virtual_machine_scale_set" "test" {
+  name = "acctvmss-%d"
+  location = "West US"
+  resource_group_name = "${azurerm_resource_group.test.name}"
+  upgrade_policy_mode = "Manual"
+
+  sku {
+    name = "Standard_A0"
+    tier = "Standard"
+    capacity = %d
+  }
+
+  os_profile {
+    computer_name_prefix = "testvm-%d"
+    admin_username = "myadmin"
+    admin_password = "Passwword1234"
+  }
+
+  network_profile {
+      name = "TestNetworkProfile-%d"
+      primary = true
+      ip_configuration {
+        name = "TestIPConfiguration"
+        subnet_id = "${azurerm_subnet.test.id}"
+      }
+  }
+
+  storage_profile_os_disk {
+    name = "osDiskProfile"
+    caching       = "ReadWrite"
+    create_option = "FromImage"
+    vhd_containers = ["${azurerm_storage_account.test.primary_blob_endpoint}${azurerm_storage_container.test.name}"]
+  }
+
+  storage_profile_image_reference {
+    publisher = "Canonical"
+    offer     = "UbuntuServer"
+    sku       = "14.04.2-LTS"
+    version   = "latest"
+  }
+}
+`
+
+var testAccAzureRMVirtualMachineScaleSet_overprovision = `
+resource "azurerm_resource_group" "test" {
+    name = "acctestrg-%d"
+    location = "West US"
+}
+
+resource "azurerm_virtual_network" "test" {
+    name = "acctvn-%d"
+    address_space = ["10.0.0.0/16"]
+    location = "West US"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+}
+
+resource "azurerm_subnet" "test" {
+    name = "acctsub-%d"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+    virtual_network_name = "${azurerm_virtual_network.test.name}"
+    address_prefix = "10.0.2.0/24"
+}
+
+resource "azurerm_network_interface" "test" {
+    name = "acctni-%d"
+    location = "West US"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+
+    ip_configuration {
+    	name = "testconfiguration1"
+    	subnet_id = "${azurerm_subnet.test.id}"
+    	private_ip_address_allocation = "dynamic"
+    }
 }
 
-//func TestAccAzureRMVirtualMachineScaleSet_basicWindowsMachine(t *testing.T) {
-//	ri := acctest.RandInt()
-//	rs := acctest.RandString(6)
-//	config := fmt.Sprintf(testAccAzureRMVirtualMachineScaleSet_basicWindows, ri, ri, ri, ri, ri, ri, rs, ri)
-//	resource.Test(t, resource.TestCase{
-//		PreCheck:     func() { testAccPreCheck(t) },
-//		Providers:    testAccProviders,
-//		CheckDestroy: testCheckAzureRMVirtualMachineScaleSetDestroy,
-//		Steps: []resource.TestStep{
-//			{
-//				Config: config,
-//				Check: resource.ComposeTestCheckFunc(
-//					testCheckAzureRMVirtualMachineScaleSetExists("azurerm_virtual_machine_scale_set.test"),
-//				),
-//			},
-//		},
-//	})
-//}
+resource "azurerm_storage_account" "test" {
+    name = "accsa%d"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+    location = "westus"
+    account_type = "Standard_LRS"
 
-func testCheckAzureRMVirtualMachineScaleSetExists(name string) resource.TestCheckFunc {
-	return func(s *terraform.State) error {
-		// Ensure we have enough information in state to look up in API
-		rs, ok := s.RootModule().Resources[name]
-		if !ok {
-			return fmt.Errorf("Not found: %s", name)
-		}
+    tags {
+        environment = "staging"
+    }
+}
 
-		name := rs.Primary.Attributes["name"]
-		resourceGroup, hasResourceGroup := rs.Primary.Attributes["resource_group_name"]
-		if !hasResourceGroup {
-			return fmt.Errorf("Bad: no resource group found in state for virtual machine: scale set %s", name)
-		}
+resource "azurerm_storage_container" "test" {
+    name = "vhds"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+    storage_account_name = "${azurerm_storage_account.test.name}"
+    container_access_type = "private"
+}
 
-		conn := testAccProvider.Meta().(*ArmClient).vmScaleSetClient
+resource "azurerm_virtual_machine_scale_set" "test" {
+  name = "acctvmss-%d"
+  location = "West US"
+  resource_group_name = "${azurerm_resource_group.test.name}"
+  upgrade_policy_mode = "Manual"
+  overprovision = false
 
-		resp, err := conn.Get(resourceGroup, name)
-		if err != nil {
-			return fmt.Errorf("Bad: Get on vmScaleSetClient: %s", err)
-		}
+  sku {
+    name = "Standard_A0"
+    tier = "Standard"
+    capacity = 2
+  }
 
-		if resp.StatusCode == http.StatusNotFound {
-			return fmt.Errorf("Bad: VirtualMachineScaleSet %q (resource group: %q) does not exist", name, resourceGroup)
-		}
+  os_profile {
+    computer_name_prefix = "testvm-%d"
+    admin_username = "myadmin"
+    admin_password = "Passwword1234"
+  }
 
-		return nil
-	}
+  network_profile {
+      name = "TestNetworkProfile-%d"
+      primary = true
+      ip_configuration {
+        name = "TestIPConfiguration"
+        subnet_id = "${azurerm_subnet.test.id}"
+      }
+  }
+
+  storage_profile_os_disk {
+    name = "osDiskProfile"
+    caching       = "ReadWrite"
+    create_option = "FromImage"
+    vhd_containers = ["${azurerm_storage_account.test.primary_blob_endpoint}${azurerm_storage_container.test.name}"]
+  }
+
+  storage_profile_image_reference {
+    publisher = "Canonical"
+    offer     = "UbuntuServer"
+    sku       = "14.04.2-LTS"
+    version   = "latest"
+  }
 }
+`
 
-func testCheckAzureRMVirtualMachineScaleSetDestroy(s *terraform.State) error {
-	conn := testAccProvider.Meta().(*ArmClient).vmScaleSetClient
+var testAccAzureRMVirtualMachineScaleSet_extension = `
+resource "azurerm_resource_group" "test" {
+    name = "acctestrg-%d"
+    location = "West US"
+}
 
-	for _, rs := range s.RootModule().Resources {
-		if rs.Type != "azurerm_virtual_machine_scale_set" {
-			continue
-		}
+resource "azurerm_virtual_network" "test" {
+    name = "acctvn-%d"
+    address_space = ["10.0.0.0/16"]
+    location = "West US"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+}
 
-		name := rs.Primary.Attributes["name"]
-		resourceGroup := rs.Primary.Attributes["resource_group_name"]
+resource "azurerm_subnet" "test" {
+    name = "acctsub-%d"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+    virtual_network_name = "${azurerm_virtual_network.test.name}"
+    address_prefix = "10.0.2.0/24"
+}
 
-		resp, err := conn.Get(resourceGroup, name)
+resource "azurerm_network_interface" "test" {
+    name = "acctni-%d"
+    location = "West US"
+    resource_group_name = "${azurerm_resource_group.test.name}"
 
-		if err != nil {
-			return nil
-		}
+    ip_configuration {
+    	name = "testconfiguration1"
+    	subnet_id = "${azurerm_subnet.test.id}"
+    	private_ip_address_allocation = "dynamic"
+    }
+}
 
-		if resp.StatusCode != http.StatusNotFound {
-			return fmt.Errorf("Virtual Machine Scale Set still exists:\n%#v", resp.Properties)
-		}
-	}
+resource "azurerm_storage_account" "test" {
+    name = "accsa%d"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+    location = "westus"
+    account_type = "Standard_LRS"
 
-	return nil
+    tags {
+        environment = "staging"
+    }
 }
 
-var testAccAzureRMVirtualMachineScaleSet_basicLinux = `
+resource "azurerm_storage_container" "test" {
+    name = "vhds"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+    storage_account_name = "${azurerm_storage_account.test.name}"
+    container_access_type = "private"
+}
+
+resource "azurerm_virtual_machine_scale_set" "test" {
+  name = "acctvmss-%d"
+  location = "West US"
+  resource_group_name = "${azurerm_resource_group.test.name}"
+  upgrade_policy_mode = "Manual"
+
+  sku {
+    name = "Standard_A0"
+    tier = "Standard"
+    capacity = 2
+  }
+
+  os_profile {
+    computer_name_prefix = "testvm-%d"
+    admin_username = "myadmin"
+    admin_password = "Passwword1234"
+  }
+
+  network_profile {
+      name = "TestNetworkProfile-%d"
+      primary = true
+      ip_configuration {
+        name = "TestIPConfiguration"
+        subnet_id = "${azurerm_subnet.test.id}"
+      }
+  }
+
+  storage_profile_os_disk {
+    name = "osDiskProfile"
+    caching       = "ReadWrite"
+    create_option = "FromImage"
+    vhd_containers = ["${azurerm_storage_account.test.primary_blob_endpoint}${azurerm_storage_container.test.name}"]
+  }
+
+  storage_profile_image_reference {
+    publisher = "Canonical"
+    offer     = "UbuntuServer"
+    sku       = "14.04.2-LTS"
+    version   = "latest"
+  }
+
+  extension {
+    name = "CustomScript"
+    publisher = "Microsoft.OSTCExtensions"
+    type = "CustomScriptForLinux"
+    type_handler_version = "1.2"
+    auto_upgrade_minor_version = true
+    settings = <<SETTINGS
+    {
+      "commandToExecute": "echo $HOSTNAME"
+    }
+SETTINGS
+  }
+}
+`
+
+var testAccAzureRMVirtualMachineScaleSet_extensionProtectedSettings = `
 resource "azurerm_resource_group" "test" {
     name = "acctestrg-%d"
     location = "West US"
@@ -191,6 +3314,76 @@ resource "azurerm_virtual_machine_scale_set" "test" {
     sku       = "14.04.2-LTS"
     version   = "latest"
   }
+
+  extension {
+    name = "CustomScript"
+    publisher = "Microsoft.OSTCExtensions"
+    type = "CustomScriptForLinux"
+    type_handler_version = "1.2"
+    auto_upgrade_minor_version = true
+    settings = <<SETTINGS
+    {
+      "commandToExecute": "echo $HOSTNAME"
+    }
+SETTINGS
+    protected_settings = <<SETTINGS
+    {
+      "storageAccountName": "${azurerm_storage_account.test.name}",
+      "storageAccountKey": "${azurerm_storage_account.test.primary_access_key}"
+    }
+SETTINGS
+  }
+}
+`
+
+var testAccAzureRMVirtualMachineScaleSet_basicLinuxNetworkOnly = `
+resource "azurerm_resource_group" "test" {
+    name = "acctestrg-%d"
+    location = "West US"
+}
+
+resource "azurerm_virtual_network" "test" {
+    name = "acctvn-%d"
+    address_space = ["10.0.0.0/16"]
+    location = "West US"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+}
+
+resource "azurerm_subnet" "test" {
+    name = "acctsub-%d"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+    virtual_network_name = "${azurerm_virtual_network.test.name}"
+    address_prefix = "10.0.2.0/24"
+}
+
+resource "azurerm_network_interface" "test" {
+    name = "acctni-%d"
+    location = "West US"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+
+    ip_configuration {
+    	name = "testconfiguration1"
+    	subnet_id = "${azurerm_subnet.test.id}"
+    	private_ip_address_allocation = "dynamic"
+    }
+}
+
+resource "azurerm_storage_account" "test" {
+    name = "accsa%d"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+    location = "westus"
+    account_type = "Standard_LRS"
+
+    tags {
+        environment = "staging"
+    }
+}
+
+resource "azurerm_storage_container" "test" {
+    name = "vhds"
+    resource_group_name = "${azurerm_resource_group.test.name}"
+    storage_account_name = "${azurerm_storage_account.test.name}"
+    container_access_type = "private"
 }
 `
 
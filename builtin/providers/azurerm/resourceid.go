@@ -88,3 +88,20 @@ func parseAzureResourceID(id string) (*ResourceID, error) {
 
 	return idObj, nil
 }
+
+// getPathValue returns the value of the given key in the Path of the
+// ResourceID. Azure does not always return path segments with consistent
+// casing, so keys which do not match exactly are matched case-insensitively.
+func (id *ResourceID) getPathValue(key string) string {
+	if value, ok := id.Path[key]; ok {
+		return value
+	}
+
+	for k, value := range id.Path {
+		if strings.EqualFold(k, key) {
+			return value
+		}
+	}
+
+	return ""
+}
@@ -117,3 +117,43 @@ func TestParseAzureResourceID(t *testing.T) {
 		}
 	}
 }
+
+func TestResourceIDGetPathValue(t *testing.T) {
+	testCases := []struct {
+		id       string
+		key      string
+		expected string
+	}{
+		{
+			"/subscriptions/6d74bdd2-9f84-11e5-9bd9-7831c1c4c038/resourceGroups/testGroup1/providers/Microsoft.Compute/virtualMachineScaleSets/scaleSet1",
+			"virtualMachineScaleSets",
+			"scaleSet1",
+		},
+		{
+			"/subscriptions/6d74bdd2-9f84-11e5-9bd9-7831c1c4c038/resourceGroups/testGroup1/providers/Microsoft.Compute/virtualmachinescalesets/scaleSet1",
+			"virtualMachineScaleSets",
+			"scaleSet1",
+		},
+		{
+			"/subscriptions/6d74bdd2-9f84-11e5-9bd9-7831c1c4c038/resourceGroups/testGroup1/providers/Microsoft.Compute/VirtualMachineScaleSets/scaleSet1",
+			"virtualMachineScaleSets",
+			"scaleSet1",
+		},
+		{
+			"/subscriptions/6d74bdd2-9f84-11e5-9bd9-7831c1c4c038/resourceGroups/testGroup1/providers/Microsoft.Compute/virtualMachines/machine1",
+			"virtualMachineScaleSets",
+			"",
+		},
+	}
+
+	for _, test := range testCases {
+		parsed, err := parseAzureResourceID(test.id)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if actual := parsed.getPathValue(test.key); actual != test.expected {
+			t.Fatalf("Expected %q for %q in %q, got %q", test.expected, test.key, test.id, actual)
+		}
+	}
+}